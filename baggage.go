@@ -0,0 +1,30 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// BaggageSource returns the value of the baggage member key carried by ctx,
+// reporting whether there is one. With OpenTelemetry:
+//
+//	func(ctx context.Context, key string) (string, bool) {
+//		m := baggage.FromContext(ctx).Member(key)
+//		return m.Value(), m.Key() != ""
+//	}
+type BaggageSource func(ctx context.Context, key string) (string, bool)
+
+// baggageFields returns the fields of the baggage keys carried by ctx.
+func (o Options) baggageFields(ctx context.Context) []zap.Field {
+	if ctx == nil || o.baggageSource == nil {
+		return nil
+	}
+	var fields []zap.Field
+	for _, key := range o.baggageKeys {
+		if v, ok := o.baggageSource(ctx, key); ok {
+			fields = append(fields, zap.String(key, v))
+		}
+	}
+	return fields
+}
@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type baggageKey struct{}
+
+func TestBaggageFields(t *testing.T) {
+	source := func(ctx context.Context, key string) (string, bool) {
+		m, _ := ctx.Value(baggageKey{}).(map[string]string)
+		v, ok := m[key]
+		return v, ok
+	}
+	log, logs := newObservedLogger(WithBaggageFields(source, "customer.tier", "region"))
+
+	ctx := context.WithValue(context.Background(), baggageKey{}, map[string]string{"customer.tier": "gold", "user": "u1"})
+	log.InfoCtx(ctx, msg)
+	log.Info(msg)
+	entries := logs.TakeAll()
+	assert.Equal(t, map[string]interface{}{"customer.tier": "gold"}, entries[0].ContextMap())
+	assert.Empty(t, entries[1].ContextMap())
+
+	_, err := NewWithError(WithBaggageFields(nil, "region"))
+	assert.Error(t, err)
+}
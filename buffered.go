@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"io"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// bufferedOutput is an output buffered by WithBufferedOutput.
+type bufferedOutput struct {
+	*zapcore.BufferedWriteSyncer
+	ws zapcore.WriteSyncer
+	// closeOutput closes ws once the buffer is flushed, false for stdout
+	// and stderr.
+	closeOutput bool
+}
+
+// Close flushes the buffer and stops its flush goroutine, then closes the
+// output.
+func (b *bufferedOutput) Close() error {
+	err := b.Stop()
+	if c, ok := b.ws.(io.Closer); ok && b.closeOutput {
+		err = multierr.Append(err, c.Close())
+	}
+	return err
+}
+
+// bufferOutput returns ws buffered as set by WithBufferedOutput, or ws itself.
+func (l *logger) bufferOutput(ws zapcore.WriteSyncer, closeOutput bool) zapcore.WriteSyncer {
+	if l.opt.bufferedOutput.size == 0 {
+		return ws
+	}
+	return &bufferedOutput{
+		BufferedWriteSyncer: &zapcore.BufferedWriteSyncer{
+			WS:            ws,
+			Size:          l.opt.bufferedOutput.size,
+			FlushInterval: l.opt.bufferedOutput.flushInterval,
+		},
+		ws:          ws,
+		closeOutput: closeOutput,
+	}
+}
+
+// bufferConsole returns stdout or stderr buffered as set by
+// WithBufferedOutput, the buffer is stopped with the other outputs.
+func (l *logger) bufferConsole(ws zapcore.WriteSyncer) zapcore.WriteSyncer {
+	ws = l.bufferOutput(ws, false)
+	if b, ok := ws.(*bufferedOutput); ok {
+		l._closers = append(l._closers, b)
+	}
+	return ws
+}
+
+// unbuffered returns the output buffered by w, or w itself.
+func unbuffered(w zapcore.WriteSyncer) zapcore.WriteSyncer {
+	if b, ok := w.(*bufferedOutput); ok {
+		return b.ws
+	}
+	return w
+}
@@ -0,0 +1,149 @@
+package logger
+
+import (
+	"runtime"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// callerCore rewrites the caller of entries before writing them: matched
+// files are stripped and configured path prefixes are trimmed. Frames of
+// wrapper packages are skipped once per entry by the logger, before the
+// entry reaches the cores.
+type callerCore struct {
+	zapcore.Core
+	trimPrefixes  []string
+	stripPatterns []string
+}
+
+// CallerMode is how the caller of entries is reported.
+type CallerMode int
+
+const (
+	// CallerShort reports the package directory, file and line, the default.
+	CallerShort CallerMode = iota
+	// CallerOff doesn't capture callers, saving the stack walk of every entry.
+	CallerOff
+	// CallerFull reports the full path of the file and the line.
+	CallerFull
+	// CallerFullWithFunction reports the full path and the function name under
+	// the func key.
+	CallerFullWithFunction
+)
+
+// wrappers is the import paths of wrapper packages registered by RegisterWrapper.
+var wrappers = struct {
+	sync.RWMutex
+	packages []string
+}{}
+
+// RegisterWrapper registers import paths of packages wrapping the logger,
+// their frames are skipped when resolving the caller of every logger
+// created afterwards, so wrappers don't need to tune WithCallerSkip or
+// WithCallDepth. Usually called from the init function of the wrapper,
+// the caller skip must not go past the wrapper frames.
+func RegisterWrapper(packages ...string) {
+	wrappers.Lock()
+	wrappers.packages = append(wrappers.packages, packages...)
+	wrappers.Unlock()
+}
+
+func registeredWrappers() []string {
+	wrappers.RLock()
+	defer wrappers.RUnlock()
+	return append([]string(nil), wrappers.packages...)
+}
+
+// callerSkipPackages returns the packages whose frames are skipped when
+// resolving callers, the registered wrappers and those of opt.
+func callerSkipPackages(opt Options) []string {
+	return append(registeredWrappers(), opt.callerSkipPackages...)
+}
+
+func newCallerCore(core zapcore.Core, opt Options) zapcore.Core {
+	if len(opt.callerTrimPrefixes) == 0 && len(opt.callerStripPatterns) == 0 {
+		return core
+	}
+	return &callerCore{
+		Core:          core,
+		trimPrefixes:  opt.callerTrimPrefixes,
+		stripPatterns: opt.callerStripPatterns,
+	}
+}
+
+func (c *callerCore) With(fields []zapcore.Field) zapcore.Core {
+	_copy := *c
+	_copy.Core = c.Core.With(fields)
+	return &_copy
+}
+
+func (c *callerCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *callerCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Caller.Defined {
+		ent.Caller = c.rewrite(ent.Caller)
+	}
+	return c.Core.Write(ent, fields)
+}
+
+func (c *callerCore) rewrite(caller zapcore.EntryCaller) zapcore.EntryCaller {
+	for _, pattern := range c.stripPatterns {
+		if strings.Contains(caller.File, pattern) {
+			return zapcore.EntryCaller{}
+		}
+	}
+
+	for _, prefix := range c.trimPrefixes {
+		if idx := strings.Index(caller.File, prefix); idx != -1 {
+			caller.File = caller.File[idx+len(prefix):]
+			break
+		}
+	}
+	return caller
+}
+
+// skipFrames walks up the stack from caller until a frame outside of the
+// packages is found, it's called by the function logging the entry.
+func skipFrames(caller zapcore.EntryCaller, packages []string) zapcore.EntryCaller {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	found := false
+	for {
+		frame, more := frames.Next()
+		if !found {
+			found = frame.PC == caller.PC
+		}
+		if found && !inPackages(frame.Function, packages) {
+			return zapcore.EntryCaller{
+				Defined:  true,
+				PC:       frame.PC,
+				File:     frame.File,
+				Line:     frame.Line,
+				Function: frame.Function,
+			}
+		}
+		if !more {
+			return caller
+		}
+	}
+}
+
+// inPackages reports whether function belongs to one of the packages,
+// function is a fully qualified name such as "github.com/a/b.(*T).Info".
+func inPackages(function string, packages []string) bool {
+	for _, pkg := range packages {
+		if strings.HasPrefix(function, pkg+".") {
+			return true
+		}
+	}
+	return false
+}
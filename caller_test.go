@@ -0,0 +1,127 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCallerCore_Rewrite(t *testing.T) {
+	_, file, _, _ := runtime.Caller(0)
+	core, logs := observer.New(zap.DebugLevel)
+	log := zap.New(newCallerCore(core, newOptions(
+		WithCallerTrimPrefixes(filepath.Dir(file)+"/"),
+	)), zap.AddCaller())
+	log.Info(msg)
+	assert.Equal(t, "caller_test.go", logs.TakeAll()[0].Caller.File)
+
+	log = zap.New(newCallerCore(core, newOptions(
+		WithCallerStripPatterns("_test.go"),
+	)), zap.AddCaller())
+	log.Info(msg)
+	assert.False(t, logs.TakeAll()[0].Caller.Defined)
+}
+
+// wrapper logs on behalf of its caller, its methods are in the package
+// "github.com/go-volo/logger.wrapper" as far as inPackages is concerned.
+type wrapper struct {
+	log Logger
+}
+
+//go:noinline
+func (w wrapper) Info(msg string) {
+	w.log.Info(msg)
+}
+
+func TestSkipFrames(t *testing.T) {
+	var dual, pipe syncBuffer
+	log := New(
+		WithConsole(false),
+		WithDisableDisk(true),
+		WithDualFormat(&dual),
+		WithPipeline(Pipeline{Output: zapcore.AddSync(&pipe)}),
+		WithCaller(CallerFullWithFunction),
+		WithCallerSkipPackages("github.com/go-volo/logger.wrapper"),
+	)
+	callerOf := func(buf *syncBuffer) (string, string) {
+		var m map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(buf.String()), &m))
+		caller, _ := m["caller"].(string)
+		fn, _ := m["func"].(string)
+		return caller, fn
+	}
+
+	wrapper{log: log}.Info(msg)
+	_, file, line, _ := runtime.Caller(0)
+	// every output reports the caller of the wrapper
+	for _, buf := range []*syncBuffer{&dual, &pipe} {
+		caller, fn := callerOf(buf)
+		assert.Equal(t, fmt.Sprintf("%s:%d", file, line-1), caller)
+		assert.Equal(t, "github.com/go-volo/logger.TestSkipFrames", fn)
+	}
+}
+
+func TestInPackages(t *testing.T) {
+	assert.True(t, inPackages("github.com/a/b.(*T).Info", []string{"github.com/a/b"}))
+	assert.False(t, inPackages("github.com/a/bc.Info", []string{"github.com/a/b"}))
+}
+
+func TestRegisterWrapper(t *testing.T) {
+	defer func(packages []string) {
+		wrappers.packages = packages
+	}(registeredWrappers())
+
+	RegisterWrapper("github.com/a/wrapper")
+	skipPackages := callerSkipPackages(newOptions(WithCallerSkipPackages("github.com/a/b")))
+	assert.Equal(t, []string{"github.com/a/wrapper", "github.com/a/b"}, skipPackages)
+}
+
+func TestWithCaller(t *testing.T) {
+	for _, tt := range []struct {
+		mode CallerMode
+		full bool
+		fn   string
+	}{
+		{CallerOff, false, ""},
+		{CallerShort, false, ""},
+		{CallerFull, true, ""},
+		{CallerFullWithFunction, true, "github.com/go-volo/logger.TestWithCaller"},
+	} {
+		var buf bytes.Buffer
+		log := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf), WithCaller(tt.mode))
+		log.Info(msg)
+
+		var m map[string]interface{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+		if tt.mode == CallerOff {
+			assert.NotContains(t, m, "caller")
+		} else {
+			caller, _ := m["caller"].(string)
+			assert.Contains(t, caller, "caller_test.go:")
+			assert.Equal(t, tt.full, filepath.IsAbs(caller))
+		}
+		if tt.fn == "" {
+			assert.NotContains(t, m, "func")
+		} else {
+			assert.Equal(t, tt.fn, m["func"])
+		}
+	}
+}
+
+func TestWithDisableCaller(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf), WithDisableCaller())
+	log.Info(msg)
+
+	var m map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	assert.NotContains(t, m, "caller")
+}
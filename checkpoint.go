@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"time"
+)
+
+const maxCheckpoints = 16
+
+// Checkpoint : Offset up to which a log file is flushed and fsynced, or only
+// flushed with SyncNever, log shippers can safely read the file up to Offset.
+type Checkpoint struct {
+	// Path is the path of the log file.
+	Path string
+	// Offset is the number of bytes safe to ship.
+	Offset int64
+	// Closed reports the file is closed and will not be written by this RollingFile anymore.
+	Closed bool
+	// Time is when the checkpoint was recorded.
+	Time time.Time
+}
+
+// Checkpoints : Checkpoints of the most recently written files, oldest first
+func (r *RollingFile) Checkpoints() []Checkpoint {
+	r.cpMutex.Lock()
+	defer r.cpMutex.Unlock()
+
+	checkpoints := make([]Checkpoint, len(r.checkpoints))
+	copy(checkpoints, r.checkpoints)
+	return checkpoints
+}
+
+// Checkpoint : Checkpoint of the file with the given path
+func (r *RollingFile) Checkpoint(path string) (Checkpoint, bool) {
+	r.cpMutex.Lock()
+	defer r.cpMutex.Unlock()
+
+	for i := len(r.checkpoints) - 1; i >= 0; i-- {
+		if r.checkpoints[i].Path == path {
+			return r.checkpoints[i], true
+		}
+	}
+	return Checkpoint{}, false
+}
+
+// recordCheckpoint records the synced offset of the active file.
+func (r *RollingFile) recordCheckpoint(closed bool) {
+	cp := Checkpoint{
+		Path:   r.filePath,
+		Offset: r.offset,
+		Closed: closed,
+		Time:   time.Now(),
+	}
+
+	r.cpMutex.Lock()
+	defer r.cpMutex.Unlock()
+
+	for i := range r.checkpoints {
+		if r.checkpoints[i].Path == cp.Path {
+			r.checkpoints = append(r.checkpoints[:i], r.checkpoints[i+1:]...)
+			break
+		}
+	}
+	r.checkpoints = append(r.checkpoints, cp)
+	if len(r.checkpoints) > maxCheckpoints {
+		r.checkpoints = r.checkpoints[len(r.checkpoints)-maxCheckpoints:]
+	}
+}
@@ -0,0 +1,98 @@
+package logger
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the algorithm used to compress rolled files.
+type Compression string
+
+const (
+	// NoCompression keeps rolled files as they are.
+	NoCompression Compression = ""
+	// GzipCompression compresses rolled files with gzip, level ranges 1..9.
+	GzipCompression Compression = "gzip"
+	// ZstdCompression compresses rolled files with zstd, level ranges 1..22.
+	ZstdCompression Compression = "zstd"
+)
+
+func (c Compression) String() string {
+	return string(c)
+}
+
+// Ext returns the file extension appended to compressed files.
+func (c Compression) Ext() string {
+	switch c {
+	case GzipCompression:
+		return ".gz"
+	case ZstdCompression:
+		return ".zst"
+	}
+	return ""
+}
+
+// compressFile compresses src into src+c.Ext() and removes src on success.
+func compressFile(src string, c Compression, level int) (err error) {
+	if c == NoCompression {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	dst := src + c.Ext()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(dst)
+		}
+	}()
+
+	w, err := newCompressWriter(out, c, level)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(w, in); err != nil {
+		w.Close()
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+
+	in.Close()
+	return os.Remove(src)
+}
+
+func newCompressWriter(w io.Writer, c Compression, level int) (io.WriteCloser, error) {
+	switch c {
+	case ZstdCompression:
+		if level == 0 {
+			return zstd.NewWriter(w)
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	default:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	}
+}
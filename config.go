@@ -0,0 +1,153 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the document read by NewFromConfig. Keys are the same in YAML and
+// JSON, absent keys keep the defaults of New.
+type Config struct {
+	Level       string `json:"level"`
+	BasePath    string `json:"basePath"`
+	Filename    string `json:"filename"`
+	Console     *bool  `json:"console"`
+	Color       *bool  `json:"color"`
+	DisableDisk *bool  `json:"disableDisk"`
+	Encoder     string `json:"encoder"`
+	// EncoderConfig overrides keys of the default zapcore.EncoderConfig, such
+	// as messageKey or timeEncoder.
+	EncoderConfig json.RawMessage `json:"encoderConfig"`
+	// Rolling is hourly, daily, monthly, minutely, secondly or a time layout
+	// such as 20060102.
+	Rolling string                 `json:"rolling"`
+	Fields  map[string]interface{} `json:"fields"`
+	// Sampling samples entries as set by WithSampling.
+	Sampling *SamplingConfig `json:"sampling"`
+}
+
+// SamplingConfig is the sampling of a Config, tick is a duration such as 1s.
+type SamplingConfig struct {
+	Tick       string `json:"tick"`
+	First      int    `json:"first"`
+	Thereafter int    `json:"thereafter"`
+}
+
+var rollingFormats = map[string]RollingFormat{
+	"monthly":  MonthlyRolling,
+	"daily":    DailyRolling,
+	"hourly":   HourlyRolling,
+	"minutely": MinutelyRolling,
+	"secondly": SecondlyRolling,
+}
+
+// LoadConfig reads a config from a YAML file, ending with .yaml or .yml, or a
+// JSON file.
+func LoadConfig(path string) (Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return parseConfig(path, b)
+}
+
+// parseConfig parses b read from path, the extension of path tells its format.
+func parseConfig(path string, b []byte) (Config, error) {
+	var (
+		c   Config
+		err error
+	)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		// decoded through JSON, so both formats share the keys and the
+		// unmarshalers of zapcore.EncoderConfig
+		var doc interface{}
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return c, fmt.Errorf("logger: parse config %s: %w", path, err)
+		}
+		if b, err = json.Marshal(doc); err != nil {
+			return c, fmt.Errorf("logger: parse config %s: %w", path, err)
+		}
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, fmt.Errorf("logger: parse config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Options returns the options set by the config.
+func (c Config) Options() ([]Option, error) {
+	var opts []Option
+	if c.Level != "" {
+		lv, err := ParseLevel(c.Level)
+		if err != nil {
+			return nil, optionError("level", "%v", err)
+		}
+		opts = append(opts, WithLevel(lv))
+	}
+	if c.BasePath != "" {
+		opts = append(opts, WithBasePath(c.BasePath))
+	}
+	if c.Filename != "" {
+		opts = append(opts, WithFilename(c.Filename))
+	}
+	if c.Console != nil {
+		opts = append(opts, WithConsole(*c.Console))
+	}
+	if c.Color != nil {
+		opts = append(opts, WithColor(*c.Color))
+	}
+	if c.DisableDisk != nil {
+		opts = append(opts, WithDisableDisk(*c.DisableDisk))
+	}
+	if c.Encoder != "" {
+		opts = append(opts, WithEncoder(Encoder(strings.ToLower(c.Encoder))))
+	}
+	if len(c.EncoderConfig) != 0 {
+		cfg := newOptions().encoderConfig
+		if err := json.Unmarshal(c.EncoderConfig, &cfg); err != nil {
+			return nil, optionError("encoderConfig", "%v", err)
+		}
+		opts = append(opts, WithEncoderConfig(cfg))
+	}
+	if c.Rolling != "" {
+		format, ok := rollingFormats[strings.ToLower(c.Rolling)]
+		if !ok {
+			format = RollingFormat(c.Rolling)
+		}
+		opts = append(opts, WithRollingFormat(format))
+	}
+	if len(c.Fields) != 0 {
+		opts = append(opts, WithFields(c.Fields))
+	}
+	if s := c.Sampling; s != nil {
+		tick, err := time.ParseDuration(s.Tick)
+		if err != nil {
+			return nil, optionError("sampling", "%v", err)
+		}
+		opts = append(opts, WithSampling(tick, s.First, s.Thereafter))
+	}
+	return opts, nil
+}
+
+// NewFromConfig creates a logger configured by the YAML or JSON file at path,
+// opts are applied after the config, such as options that can't be expressed
+// in a file. Like NewWithError, it returns invalid options instead of
+// panicking.
+func NewFromConfig(path string, opts ...Option) (Logger, error) {
+	c, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	cfgOpts, err := c.Options()
+	if err != nil {
+		return nil, err
+	}
+	return NewWithError(append(cfgOpts, opts...)...)
+}
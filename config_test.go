@@ -0,0 +1,70 @@
+package logger
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFromConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logger.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+level: warn
+basePath: `+dir+`
+filename: app
+console: false
+disableDisk: false
+encoder: json
+encoderConfig:
+  messageKey: message
+  timeEncoder:
+    layout: "2006-01-02"
+rolling: daily
+fields:
+  service: api
+sampling:
+  tick: 1s
+  first: 10
+  thereafter: 5
+`), 0644))
+
+	l, err := NewFromConfig(path)
+	assert.NoError(t, err)
+	defer l.(Closer).Close(context.Background())
+	opt := l.Options()
+	assert.Equal(t, Level(WarnLevel), opt.level)
+	assert.Equal(t, dir, opt.basePath)
+	assert.Equal(t, "app", opt.filename)
+	assert.False(t, opt.console)
+	assert.False(t, opt.disableDisk)
+	assert.Equal(t, "message", opt.encoderConfig.MessageKey)
+	// keys absent from the config keep their defaults
+	assert.Equal(t, "level", opt.encoderConfig.LevelKey)
+	assert.Equal(t, RollingFormat(DailyRolling), opt.rollingFormat)
+	assert.Equal(t, "api", opt.fields["service"])
+	assert.Equal(t, sampling{tick: time.Second, first: 10, thereafter: 5}, opt.sampling)
+}
+
+func TestNewFromConfig_JSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logger.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"level": "error", "rolling": "20060102"}`), 0644))
+
+	c, err := LoadConfig(path)
+	assert.NoError(t, err)
+	opts, err := c.Options()
+	assert.NoError(t, err)
+	opt := newOptions(opts...)
+	assert.Equal(t, Level(ErrorLevel), opt.level)
+	assert.Equal(t, RollingFormat(DailyRolling), opt.rollingFormat)
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"level": "verbose"}`), 0644))
+	_, err = NewFromConfig(path)
+	var optErr *OptionError
+	assert.ErrorAs(t, err, &optErr)
+	assert.Equal(t, "level", optErr.Option)
+}
@@ -0,0 +1,128 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"go.uber.org/zap"
+)
+
+type (
+	contextKey       struct{}
+	contextFieldsKey struct{}
+)
+
+// NewContext returns a copy of ctx carrying l, such as a logger with the
+// fields of a request, retrieved by FromContext down the call stack.
+func NewContext(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the logger carried by ctx, or DefaultLogger if none.
+func FromContext(ctx context.Context) Logger {
+	if ctx != nil {
+		if l, ok := ctx.Value(contextKey{}).(Logger); ok {
+			return l
+		}
+	}
+	return DefaultLogger
+}
+
+// AppendCtxFields returns a copy of ctx carrying keysAndValues after the
+// fields ctx carries already. Loggers given the context by WithContext add
+// them to every entry, before the fields of the entry, so a middleware can
+// enrich entries without owning the logger.
+func AppendCtxFields(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	kv := ctxFields(ctx)
+	return context.WithValue(ctx, contextFieldsKey{}, append(kv[:len(kv):len(kv)], keysAndValues...))
+}
+
+// ctxFields returns the key-value pairs carried by ctx.
+func ctxFields(ctx context.Context) []interface{} {
+	if ctx == nil {
+		return nil
+	}
+	kv, _ := ctx.Value(contextFieldsKey{}).([]interface{})
+	return kv
+}
+
+// fieldScope is a frame of fields pushed to a context on top of the frames
+// of its parent. Frames are never modified, so contexts derived from the same
+// parent, e.g. by concurrent goroutines, push and pop independently.
+type fieldScope struct {
+	parent *fieldScope
+	kv     []interface{}
+}
+
+type fieldScopeKey struct{}
+
+// PushFields returns a copy of ctx carrying keysAndValues on top of the
+// fields pushed to ctx, as a call goes through layers, e.g.
+//
+//	ctx = logger.PushFields(ctx, "step", "parse")
+//
+// Loggers given the context add the fields pushed to entries, after the
+// fields of AppendCtxFields. Returning to ctx, or PopFields, drops them.
+func PushFields(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	parent, _ := ctx.Value(fieldScopeKey{}).(*fieldScope)
+	return context.WithValue(ctx, fieldScopeKey{}, &fieldScope{parent: parent, kv: keysAndValues})
+}
+
+// PopFields returns a copy of ctx without the fields of the last PushFields.
+func PopFields(ctx context.Context) context.Context {
+	s, ok := ctx.Value(fieldScopeKey{}).(*fieldScope)
+	if !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, fieldScopeKey{}, s.parent)
+}
+
+// scopeFields returns the key-value pairs pushed to ctx, bottom first.
+func scopeFields(ctx context.Context) []interface{} {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(fieldScopeKey{}).(*fieldScope)
+	var frames [][]interface{}
+	for ; s != nil; s = s.parent {
+		frames = append(frames, s.kv)
+	}
+	var kv []interface{}
+	for i := len(frames) - 1; i >= 0; i-- {
+		kv = append(kv, frames[i]...)
+	}
+	return kv
+}
+
+// contextKeyFields returns the fields of the context keys carried by ctx.
+func (o Options) contextKeyFields(ctx context.Context) []zap.Field {
+	if ctx == nil {
+		return nil
+	}
+	var fields []zap.Field
+	for _, key := range o.contextKeys {
+		name, ok := contextKeyName(key)
+		if !ok {
+			// reported by Validate
+			continue
+		}
+		if v := ctx.Value(key); v != nil {
+			fields = append(fields, zap.Any(name, v))
+		}
+	}
+	return fields
+}
+
+// contextKeyName returns the field name of key, the String method of key or
+// key itself if of a string type, reporting whether key has a name. Other
+// keys, typically empty structs, would all print alike.
+func contextKeyName(key interface{}) (string, bool) {
+	if k, ok := key.(fmt.Stringer); ok {
+		return k.String(), true
+	}
+	if v := reflect.ValueOf(key); v.Kind() == reflect.String {
+		return v.String(), true
+	}
+	return "", false
+}
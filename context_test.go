@@ -0,0 +1,226 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestContext(t *testing.T) {
+	log, logs := newObservedLogger()
+	ctx := NewContext(context.Background(), log.WithFields(map[string]interface{}{"request_id": "r1"}))
+
+	FromContext(ctx).Info(msg)
+	assert.Equal(t, map[string]interface{}{"request_id": "r1"}, logs.TakeAll()[0].ContextMap())
+
+	assert.Equal(t, DefaultLogger, FromContext(context.Background()))
+}
+
+func TestAppendCtxFields(t *testing.T) {
+	log, logs := newObservedLogger()
+	ctx := AppendCtxFields(context.Background(), "request_id", "r1")
+	ctx = AppendCtxFields(ctx, "user", "u1")
+
+	log.WithContext(ctx).Infow(msg, "id", 1)
+	assert.Equal(t, map[string]interface{}{"request_id": "r1", "user": "u1", "id": int64(1)}, logs.TakeAll()[0].ContextMap())
+
+	log.Info(msg)
+	assert.Empty(t, logs.TakeAll()[0].ContextMap())
+}
+
+func TestWithDebugCtx(t *testing.T) {
+	dir := t.TempDir()
+	log := New(
+		WithBasePath(dir),
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithLevel(ErrorLevel),
+		WithRollingFunc(func(name string, t time.Time) (string, string) {
+			return "", name
+		}),
+	)
+	defer log.(Closer).Close(context.Background())
+
+	log.Info("dropped")
+	log.WithFields(map[string]interface{}{"request_id": "r1"}).WithContext(WithDebugCtx(context.Background())).Info(msg)
+	log.WithContext(WithDebugCtx(context.Background())).Warn(msg)
+	assert.NoError(t, log.Sync())
+
+	b, err := os.ReadFile(filepath.Join(dir, "info.log"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(b), "dropped")
+	assert.Equal(t, 1, strings.Count(string(b), msg))
+	assert.Contains(t, string(b), `"request_id":"r1"`)
+	b, err = os.ReadFile(filepath.Join(dir, "warn.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(b), msg))
+
+	// the debug logger is built once
+	base := log.(*logger).base(log.(*logger).outputs())
+	assert.Same(t, base.debugBase(), base.debugBase())
+}
+
+// dropCore drops the entries of its message in Check.
+type dropCore struct {
+	zapcore.Core
+	msg string
+}
+
+func (c dropCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Message == c.msg {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+func TestDebugCoreCheck(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	c := &debugCore{Core: dropCore{Core: core, msg: "dropped"}, match: anyLevel}
+
+	// enabled entries are checked by the core
+	for _, ent := range []zapcore.Entry{
+		{Level: zap.WarnLevel, Message: "dropped"},
+		{Level: zap.WarnLevel, Message: msg},
+		{Level: zap.DebugLevel, Message: "dropped"},
+	} {
+		if ce := c.Check(ent, nil); ce != nil {
+			ce.Write()
+		}
+	}
+	entries := logs.TakeAll()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, msg, entries[0].Message)
+	assert.Equal(t, "dropped", entries[1].Message)
+}
+
+func TestCtxMethods(t *testing.T) {
+	log, logs := newObservedLogger()
+	ctx := AppendCtxFields(context.Background(), "request_id", "r1")
+
+	log.InfoCtx(ctx, msg, "id", 1)
+	log.WarnCtx(ctx, msg)
+	log.ErrorCtx(context.Background(), msg)
+	entries := logs.TakeAll()
+	assert.Len(t, entries, 3)
+	assert.Equal(t, map[string]interface{}{"request_id": "r1", "id": int64(1)}, entries[0].ContextMap())
+	assert.Equal(t, map[string]interface{}{"request_id": "r1"}, entries[1].ContextMap())
+	assert.Empty(t, entries[2].ContextMap())
+}
+
+func TestPushFields(t *testing.T) {
+	log, logs := newObservedLogger()
+	ctx := AppendCtxFields(context.Background(), "request_id", "r1")
+	parse := PushFields(ctx, "step", "parse")
+	token := PushFields(parse, "token", 1)
+	// frames of the same parent don't see each other
+	other := PushFields(parse, "token", 2)
+
+	log.WithContext(token).Info(msg)
+	log.WithContext(other).Info(msg)
+	log.WithContext(PopFields(token)).Info(msg)
+	log.WithContext(PopFields(PopFields(ctx))).Info(msg)
+
+	entries := logs.TakeAll()
+	assert.Equal(t, map[string]interface{}{"request_id": "r1", "step": "parse", "token": int64(1)}, entries[0].ContextMap())
+	assert.Equal(t, map[string]interface{}{"request_id": "r1", "step": "parse", "token": int64(2)}, entries[1].ContextMap())
+	assert.Equal(t, map[string]interface{}{"request_id": "r1", "step": "parse"}, entries[2].ContextMap())
+	assert.Equal(t, map[string]interface{}{"request_id": "r1"}, entries[3].ContextMap())
+}
+
+type (
+	traceName string
+	tenantKey struct{}
+)
+
+func (tenantKey) String() string { return "tenant" }
+
+func TestContextKeys(t *testing.T) {
+	log, logs := newObservedLogger(WithContextKeys(traceName("trace"), tenantKey{}, traceName("missing")))
+	ctx := context.WithValue(context.Background(), traceName("trace"), "t1")
+	ctx = context.WithValue(ctx, tenantKey{}, 7)
+
+	log.InfoCtx(ctx, msg)
+	assert.Equal(t, map[string]interface{}{"trace": "t1", "tenant": int64(7)}, logs.TakeAll()[0].ContextMap())
+
+	// keys without a name are skipped and reported
+	log, logs = newObservedLogger(WithContextKeys(sampledKey{}, traceName("trace")))
+	log.InfoCtx(context.WithValue(ctx, sampledKey{}, true), msg)
+	assert.Equal(t, map[string]interface{}{"trace": "t1"}, logs.TakeAll()[0].ContextMap())
+
+	var options []string
+	for _, err := range multierr.Errors(newOptions(WithContextKeys(sampledKey{}, traceName("tenant"), tenantKey{})).Validate()) {
+		var optErr *OptionError
+		assert.True(t, errors.As(err, &optErr))
+		options = append(options, optErr.Reason)
+	}
+	assert.Equal(t, []string{
+		"key of type logger.sampledKey must be a string or a fmt.Stringer",
+		`keys named "tenant" alike`,
+	}, options)
+}
+
+type sampledKey struct{}
+
+func TestSampledTraceLevel(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(
+		WithConsole(false),
+		WithDisableDisk(true),
+		WithDualFormat(&buf),
+		WithLevel(ErrorLevel),
+		WithSampledTraceLevel(InfoLevel, func(ctx context.Context) bool {
+			return ctx.Value(sampledKey{}) != nil
+		}),
+	)
+	sampled := context.WithValue(context.Background(), sampledKey{}, true)
+
+	log.(ContextLogger).InfoCtx(context.Background(), "unsampled")
+	log.(ContextLogger).InfoCtx(sampled, msg)
+	log.(ContextLogger).WarnCtx(sampled, msg)
+	assert.NotContains(t, buf.String(), "unsampled")
+	assert.Equal(t, 2, strings.Count(buf.String(), msg))
+}
+
+func TestWithContextNil(t *testing.T) {
+	log, logs := newObservedLogger()
+	var ctxValue Valuer = func(ctx context.Context) interface{} {
+		return ctx.Value(traceName("trace"))
+	}
+
+	child := log.WithContext(nil)
+	assert.NotPanics(t, func() { child.Infof("%v %v", "trace", ctxValue) })
+	// loggers of New and the Ctx methods given nil too
+	assert.NotPanics(t, func() { log.Infof("%v %v", "trace", ctxValue) })
+	assert.NotPanics(t, func() { log.InfoCtx(nil, msg, "trace", ctxValue) })
+	assert.Len(t, logs.TakeAll(), 3)
+
+	// derived loggers keep the context
+	ctx := context.WithValue(context.Background(), traceName("trace"), "t1")
+	log.WithContext(ctx).WithFields(map[string]interface{}{"user": "u1"}).WithCallDepth(1).Infof("%v %v", "trace", ctxValue)
+	assert.Equal(t, "trace t1", logs.TakeAll()[0].Message)
+
+	// the level and outputs are shared with the parent
+	var buf bytes.Buffer
+	parent := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf))
+	child = parent.WithContext(nil)
+	child.SetLevel(ErrorLevel)
+	parent.Info("dropped")
+	child.Error(msg)
+	assert.NotContains(t, buf.String(), "dropped")
+	assert.Contains(t, buf.String(), msg)
+
+	// Init of the child is seen by the Options of the parent
+	assert.NoError(t, child.Init(WithFields(map[string]interface{}{"app": "api"})))
+	assert.Equal(t, map[string]interface{}{"app": "api"}, parent.Options().fields)
+}
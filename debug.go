@@ -0,0 +1,65 @@
+//go:build !logger_nodebug
+// +build !logger_nodebug
+
+package logger
+
+import (
+	"context"
+)
+
+// Debug uses fmt.Sprint to construct and log a message.
+func Debug(args ...interface{}) {
+	DefaultLogger.WithCallDepth(1).Debug(args...)
+}
+
+// Debugf uses fmt.Sprintf to log a templated message.
+func Debugf(format string, args ...interface{}) {
+	DefaultLogger.WithCallDepth(1).Debugf(format, args...)
+}
+
+// Debugw logs a message with some additional context. The variadic key-value
+// pairs are treated as they are in With.
+//
+// When debug-level logging is disabled, this is much faster than
+//
+//	s.With(keysAndValues).Debug(msg)
+func Debugw(msg string, keysAndValues ...interface{}) {
+	DefaultLogger.WithCallDepth(1).Debugw(msg, keysAndValues...)
+}
+
+// DebugCtx logs a message with some additional context like Debugw, ctx is
+// used as by WithContext.
+func DebugCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	l := DefaultLogger.WithCallDepth(1)
+	if c, ok := l.(ContextLogger); ok {
+		c.DebugCtx(ctx, msg, keysAndValues...)
+		return
+	}
+	l.WithContext(ctx).Debugw(msg, keysAndValues...)
+}
+
+// Debug uses fmt.Sprint to construct and log a message.
+func (l *logger) Debug(args ...interface{}) {
+	l.log(l.ctx, DebugLevel, "", args, nil)
+}
+
+// Debugf uses fmt.Sprintf to log a templated message.
+func (l *logger) Debugf(template string, args ...interface{}) {
+	l.log(l.ctx, DebugLevel, template, args, nil)
+}
+
+// Debugw logs a message with some additional context. The variadic key-value
+// pairs are treated as they are in With.
+//
+// When debug-level logging is disabled, this is much faster than
+//
+//	s.With(keysAndValues).Debug(msg)
+func (l *logger) Debugw(msg string, keysAndValues ...interface{}) {
+	l.log(l.ctx, DebugLevel, msg, nil, keysAndValues)
+}
+
+// DebugCtx logs a message with some additional context like Debugw, ctx is
+// used as by WithContext without cloning l.
+func (l *logger) DebugCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	l.log(ctx, DebugLevel, msg, nil, keysAndValues)
+}
@@ -0,0 +1,36 @@
+//go:build logger_nodebug
+// +build logger_nodebug
+
+package logger
+
+import (
+	"context"
+)
+
+// Debug and its variants compile to empty functions under the logger_nodebug
+// build tag, so the compiler inlines them away in latency critical builds.
+// Arguments are still evaluated at call sites unless they are constants.
+
+// Debug is a no-op under the logger_nodebug build tag.
+func Debug(args ...interface{}) {}
+
+// Debugf is a no-op under the logger_nodebug build tag.
+func Debugf(format string, args ...interface{}) {}
+
+// Debugw is a no-op under the logger_nodebug build tag.
+func Debugw(msg string, keysAndValues ...interface{}) {}
+
+// DebugCtx is a no-op under the logger_nodebug build tag.
+func DebugCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {}
+
+// Debug is a no-op under the logger_nodebug build tag.
+func (l *logger) Debug(args ...interface{}) {}
+
+// Debugf is a no-op under the logger_nodebug build tag.
+func (l *logger) Debugf(template string, args ...interface{}) {}
+
+// Debugw is a no-op under the logger_nodebug build tag.
+func (l *logger) Debugw(msg string, keysAndValues ...interface{}) {}
+
+// DebugCtx is a no-op under the logger_nodebug build tag.
+func (l *logger) DebugCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {}
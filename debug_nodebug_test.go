@@ -0,0 +1,18 @@
+//go:build logger_nodebug
+// +build logger_nodebug
+
+package logger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoDebug(t *testing.T) {
+	log, logs := newObservedLogger(WithLevel(DebugLevel))
+	log.Debug(msg)
+	log.Debugf(msg)
+	log.Debugw(msg)
+	assert.Zero(t, logs.Len())
+}
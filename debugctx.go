@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type debugCtxKey struct{}
+
+// WithDebugCtx returns a copy of ctx that enables every level for the entries
+// of loggers given the context by WithContext, whatever the level of the
+// logger, so a single request can be traced verbosely in production. Entries
+// are written to the outputs of their level, outputs of a fixed level, such
+// as pipelines, keep it.
+func WithDebugCtx(ctx context.Context) context.Context {
+	return context.WithValue(ctx, debugCtxKey{}, true)
+}
+
+// elevated reports whether entries of lv with ctx are logged whatever the
+// level of the logger, set by WithDebugCtx or WithSampledTraceLevel.
+func (o Options) elevated(ctx context.Context, lv Level) bool {
+	if ctx == nil {
+		return false
+	}
+	if isDebugCtx(ctx) {
+		return true
+	}
+	return o.sampledTrace != nil && lv >= o.sampledTraceLevel && o.sampledTrace(ctx)
+}
+
+func isDebugCtx(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	debug, _ := ctx.Value(debugCtxKey{}).(bool)
+	return debug
+}
+
+// levelCore marks a core written the entries of the levels matched only,
+// rather than the entries enabled by the level of the logger. It's unwrapped
+// by build.
+type levelCore struct {
+	zapcore.Core
+	match func(zapcore.Level) bool
+}
+
+// debugCore writes the entries of the levels matched by its core, whether the
+// core is enabled or not. Entries the core is enabled for are checked by it,
+// so that its wrappers, such as filters, still apply. Other entries are added
+// without the check of the core, which would drop them.
+type debugCore struct {
+	zapcore.Core
+	match func(zapcore.Level) bool
+}
+
+func anyLevel(zapcore.Level) bool {
+	return true
+}
+
+func (c *debugCore) Enabled(lvl zapcore.Level) bool {
+	return c.match(lvl)
+}
+
+func (c *debugCore) With(fields []zapcore.Field) zapcore.Core {
+	return &debugCore{Core: c.Core.With(fields), match: c.match}
+}
+
+func (c *debugCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Core.Enabled(ent.Level) {
+		return c.Core.Check(ent, ce)
+	}
+	if c.match(ent.Level) {
+		return ce.AddCore(ent, c.Core)
+	}
+	return ce
+}
+
+// debugBase returns the base logger of l writing to the debug cores of the
+// outputs, built once per base. Cores wrapped by WithZapOptions can't be
+// unwrapped, their entries are dropped as usual.
+func (b *loggerBase) debugBase() *zap.Logger {
+	b.debugOnce.Do(func() {
+		b.debug = b.base.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			if c, ok := core.(*reloadCore); ok {
+				return c.debugCore()
+			}
+			return core
+		}))
+	})
+	return b.debug
+}
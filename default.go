@@ -3,29 +3,125 @@ package logger
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
 
 	"go.uber.org/multierr"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-var _ Logger = (*logger)(nil)
+var (
+	_ Logger        = (*logger)(nil)
+	_ ContextLogger = (*logger)(nil)
+	_ FieldReplacer = (*logger)(nil)
+	_ Namespacer    = (*logger)(nil)
+	_ Flusher       = (*logger)(nil)
+	_ Closer        = (*logger)(nil)
+)
 
 type logger struct {
+	// opt are the options the outputs are built from, only set while
+	// building: the options in use are those of the current outputs.
 	opt           Options
-	base          *zap.Logger
 	ctx           context.Context
 	atomicLevel   zap.AtomicLevel
 	_writeSyncers []zapcore.WriteSyncer
+	// _closers are outputs not listed in _writeSyncers, such as shard sets.
+	_closers   []io.Closer
+	_sequences []*sequence
+	// state holds the current outputs, swapped when the configuration is reloaded.
+	state *outputState
+
+	// derived loggers add the fields with to those of parent, or drop the
+	// fields keyed by without, and add callDepth to its caller skip. Their
+	// base is derived again from the one of parent when the outputs are
+	// swapped.
+	parent    *logger
+	with      []zap.Field
+	without   []string
+	callDepth int
+	bases     *atomic.Value // *loggerBase
+}
+
+// loggerBase is the zap logger of a logger for given outputs.
+type loggerBase struct {
+	outputs *outputs
+	fields  []zap.Field
+	depth   int
+	base    *zap.Logger
+	// debug is base writing to the debug cores, built by the first elevated
+	// entry.
+	debugOnce sync.Once
+	debug     *zap.Logger
+}
+
+// base returns the zap logger of l writing to out, built once per outputs.
+func (l *logger) base(out *outputs) *loggerBase {
+	if b, ok := l.bases.Load().(*loggerBase); ok && b.outputs == out {
+		return b
+	}
+	b := &loggerBase{outputs: out}
+	switch {
+	case l.parent == nil:
+		b.fields, b.base = out.fields, out.root.With(out.fields...)
+	case l.without != nil:
+		p := l.parent.base(out)
+		b.depth = p.depth
+		b.fields = make([]zap.Field, 0, len(p.fields))
+		for _, f := range p.fields {
+			if f.Type == zapcore.NamespaceType || !containsString(l.without, f.Key) {
+				b.fields = append(b.fields, f)
+			}
+		}
+		b.base = out.root.WithOptions(zap.AddCallerSkip(b.depth)).With(b.fields...)
+	default:
+		p := l.parent.base(out)
+		b.depth = p.depth + l.callDepth
+		b.fields = append(p.fields[:len(p.fields):len(p.fields)], l.with...)
+		b.base = p.base
+		if l.callDepth != 0 {
+			b.base = b.base.WithOptions(zap.AddCallerSkip(l.callDepth))
+		}
+		if len(l.with) > 0 {
+			b.base = b.base.With(l.with...)
+		}
+	}
+	l.bases.Store(b)
+	return b
+}
+
+// derive returns a logger derived from l, sharing its context, level and
+// outputs.
+func (l *logger) derive() *logger {
+	return &logger{
+		ctx:         l.ctx,
+		atomicLevel: l.atomicLevel,
+		state:       l.state,
+		parent:      l,
+		bases:       new(atomic.Value),
+	}
 }
 
+// New creates a logger of opts, invalid options reported by Validate are
+// written to the error output before building it. NewWithError returns them
+// instead.
 func New(opts ...Option) Logger {
 	opt := newOptions(opts...)
+	if err := opt.Validate(); err != nil {
+		// NewWithError fails instead, such as on an unknown level string,
+		// which keeps the default level here
+		opt.reportOptionError(err)
+	}
 	l := &logger{
 		opt:         opt,
 		atomicLevel: zap.NewAtomicLevelAt(opt.level.unmarshalZapLevel()),
+		bases:       new(atomic.Value),
 	}
 
 	if err := l.build(); err != nil {
@@ -34,7 +130,15 @@ func New(opts ...Option) Logger {
 	return l
 }
 
-func (l *logger) build() error {
+// build creates the outputs of l.opt and stores them into the state of l,
+// outputs created before an error are closed.
+func (l *logger) build() (err error) {
+	defer func() {
+		if err != nil {
+			closeOutputs(l._writeSyncers, l._closers)
+		}
+	}()
+
 	var (
 		cores []zapcore.Core
 	)
@@ -60,36 +164,176 @@ func (l *logger) build() error {
 		cores = append(cores, _cores...)
 	}
 
-	zapLog := zap.New(zapcore.NewTee(cores...)).WithOptions(zap.AddCaller(), zap.AddCallerSkip(l.opt.callerSkip))
-	if l.opt.fields != nil {
-		zapLog = zapLog.With(CopyFields(l.opt.fields)...)
+	if l.opt.shardKey != "" && !l.opt.disableDisk {
+		shards := l.buildShards()
+		l._closers = append(l._closers, shards.shards)
+		cores = append(cores, newMonotonicCore(shards, newMonotonicClock(l.opt)))
 	}
-	if l.opt.namespace != "" {
-		zapLog = zapLog.With(zap.Namespace(l.opt.namespace))
+
+	if l.opt.jsonCopy != nil {
+		// the copy is written by the caller, who closes it
+		core := zapcore.NewCore(zapcore.NewJSONEncoder(l.opt.encoderConfig), l.opt.jsonCopy, l.atomicLevel)
+		cores = append(cores, newMonotonicCore(core, newMonotonicClock(l.opt)))
 	}
 
-	l.base = zapLog
+	cores = append(cores, l.buildPipelines()...)
 
+	debugCores := make([]zapcore.Core, len(cores))
+	for i := range cores {
+		match := anyLevel
+		if c, ok := cores[i].(*levelCore); ok {
+			match, cores[i] = c.match, c.Core
+		}
+		if _, ok := cores[i].(*pipelineCore); !ok && len(l.opt.pipelines) > 0 {
+			cores[i] = &noContextCore{Core: cores[i]}
+		}
+		cores[i] = newCallerCore(cores[i], l.opt)
+		debugCores[i] = &debugCore{Core: cores[i], match: match}
+	}
+	core := zapcore.NewTee(cores...)
+	if s := l.opt.sampling; s.tick > 0 {
+		core = zapcore.NewSamplerWithOptions(core, s.tick, s.first, s.thereafter)
+	}
+
+	if l.state == nil {
+		l.state = &outputState{}
+	}
+
+	zapOpts := []zap.Option{
+		zap.WithCaller(l.opt.callerMode != CallerOff),
+		zap.AddCallerSkip(l.opt.callerSkip),
+		zap.WithFatalHook(fatalHook{state: l.state, hook: l.opt.fatalHook, exit: l.opt.exitFunc}),
+	}
+	if l.opt.development {
+		zapOpts = append(zapOpts, zap.Development())
+	}
+	if l.opt.clock != nil {
+		zapOpts = append(zapOpts, zap.WithClock(l.opt.clock))
+	}
+	if l.opt.errorOutput != nil {
+		zapOpts = append(zapOpts, zap.ErrorOutput(l.opt.errorOutput))
+	}
+	if l.opt.stacktraceLevel != 0 {
+		zapOpts = append(zapOpts, zap.AddStacktrace(l.opt.stacktraceLevel.unmarshalZapLevel()))
+	}
+	// last, to override the options above
+	zapOpts = append(zapOpts, l.opt.zapOptions...)
+	var fields []zap.Field
+	if l.opt.fields != nil {
+		fields = append(fields, l.opt.interner.internFields(CopyFields(bindFields(context.Background(), l.opt.fields)))...)
+	}
+	if l.opt.runtimeFields {
+		fields = append(fields, runtimeFields(l.opt.appVersion)...)
+	}
+	if len(l.opt.resourceDetectors) > 0 {
+		fields = append(fields, DetectResource(l.opt.resourceDetectors...).fields()...)
+	}
+	if l.opt.namespace != "" {
+		fields = append(fields, zap.Namespace(l.opt.namespace))
+	}
+
+	l.state.current.Store(&outputs{
+		opt:          l.opt,
+		core:         core,
+		debugCore:    zapcore.NewTee(debugCores...),
+		root:         zap.New(newReloadCore(l.state)).WithOptions(zapOpts...),
+		fields:       fields,
+		skipPackages: callerSkipPackages(l.opt),
+		writeSyncers: l._writeSyncers,
+		closers:      l._closers,
+		sequences:    l._sequences,
+	})
 	return nil
 }
 
 func (l *logger) buildEncoder(cfg Options) zapcore.Encoder {
+	if cfg.encoderFactory != nil {
+		return cfg.encoderFactory(cfg.encoderConfig)
+	}
 	if cfg.encoder.IsConsole() {
+		if cfg.consoleProfile != nil {
+			return zapcore.NewConsoleEncoder(cfg.consoleProfile.apply(cfg.encoderConfig))
+		}
 		return zapcore.NewConsoleEncoder(cfg.encoderConfig)
 	}
 	return zapcore.NewJSONEncoder(cfg.encoderConfig)
 }
 
+// buildConsoleEncoder returns the encoder of console outputs, which renders
+// colored text in dual format mode, or with color enabled when stdout is a
+// terminal.
+func (l *logger) buildConsoleEncoder() zapcore.Encoder {
+	cfg := l.opt
+	if cfg.consoleEncoder != "" {
+		cfg.encoder = cfg.consoleEncoder
+		cfg.encoderFactory = nil
+	}
+	if cfg.jsonCopy != nil {
+		cfg.encoder = ConsoleEncoder
+		cfg.encoderFactory = nil
+		cfg.encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	} else if cfg.color && cfg.encoder.IsConsole() && isTerminal(os.Stdout) {
+		cfg.encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+	}
+	return l.buildEncoder(cfg)
+}
+
+// buildFileEncoder returns the encoder of file outputs.
+func (l *logger) buildFileEncoder() zapcore.Encoder {
+	cfg := l.opt
+	if cfg.fileEncoder != "" {
+		cfg.encoder = cfg.fileEncoder
+		cfg.encoderFactory = nil
+	}
+	return l.buildEncoder(cfg)
+}
+
+// isTerminal reports whether f is a terminal rather than a file or a pipe.
+var isTerminal = func(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 func (l *logger) LevelEnablerFunc(level zapcore.Level) zap.LevelEnablerFunc {
 	enabled := l.atomicLevel.Enabled(level)
-	if level == zapcore.FatalLevel {
+	match := levelMatcher(level)
+	return func(lvl zapcore.Level) bool {
+		return enabled && match(lvl)
+	}
+}
+
+// levelMatcher returns whether entries of a level go to the output of level.
+func levelMatcher(level zapcore.Level) func(zapcore.Level) bool {
+	switch level {
+	case zapcore.FatalLevel:
+		return func(lvl zapcore.Level) bool {
+			return lvl >= level
+		}
+	case zapcore.ErrorLevel:
+		// DPanic and Panic entries, only logged by zap itself, go along errors
 		return func(lvl zapcore.Level) bool {
-			return enabled && lvl >= level
+			return lvl >= level && lvl < zapcore.FatalLevel
 		}
 	}
 	return func(lvl zapcore.Level) bool {
-		return enabled && lvl == level
+		return lvl == level
+	}
+}
+
+// newLevelCore returns a core writing the entries of level to ws, clock is the
+// monotonic clock shared by the cores writing to ws.
+func (l *logger) newLevelCore(enc zapcore.Encoder, ws zapcore.WriteSyncer, clock *monotonicClock, level zapcore.Level) zapcore.Core {
+	core := newMonotonicCore(zapcore.NewCore(enc, ws, l.LevelEnablerFunc(level)), clock)
+	return &levelCore{Core: core, match: levelMatcher(level)}
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
 	}
+	return false
 }
 
 func CopyFields(fields map[string]interface{}) []zap.Field {
@@ -101,21 +345,23 @@ func CopyFields(fields map[string]interface{}) []zap.Field {
 }
 
 func (l *logger) buildConsole() []zapcore.Core {
-	syncerStdout := zapcore.AddSync(os.Stdout)
-	syncerStderr := zapcore.AddSync(os.Stderr)
-	enc := l.buildEncoder(l.opt)
+	syncerStdout := l.bufferConsole(zapcore.AddSync(os.Stdout))
+	syncerStderr := l.bufferConsole(zapcore.AddSync(os.Stderr))
+	clockStdout, clockStderr := newMonotonicClock(l.opt), newMonotonicClock(l.opt)
+	enc := l.buildConsoleEncoder()
 
 	return []zapcore.Core{
-		zapcore.NewCore(enc, syncerStdout, l.LevelEnablerFunc(zap.DebugLevel)),
-		zapcore.NewCore(enc, syncerStdout, l.LevelEnablerFunc(zap.InfoLevel)),
-		zapcore.NewCore(enc, syncerStdout, l.LevelEnablerFunc(zap.WarnLevel)),
-		zapcore.NewCore(enc, syncerStderr, l.LevelEnablerFunc(zap.ErrorLevel)),
-		zapcore.NewCore(enc, syncerStderr, l.LevelEnablerFunc(zap.FatalLevel)),
+		l.newLevelCore(enc, syncerStdout, clockStdout, zap.DebugLevel),
+		l.newLevelCore(enc, syncerStdout, clockStdout, zap.InfoLevel),
+		l.newLevelCore(enc, syncerStdout, clockStdout, zap.WarnLevel),
+		l.newLevelCore(enc, syncerStderr, clockStderr, zap.ErrorLevel),
+		l.newLevelCore(enc, syncerStderr, clockStderr, zap.FatalLevel),
 	}
 }
 
 func (l *logger) buildFileConsole() zapcore.Core {
-	return zapcore.NewCore(l.buildEncoder(l.opt), zapcore.AddSync(os.Stdout), l.atomicLevel)
+	core := zapcore.NewCore(l.buildConsoleEncoder(), l.bufferConsole(zapcore.AddSync(os.Stdout)), l.atomicLevel)
+	return newMonotonicCore(core, newMonotonicClock(l.opt))
 }
 
 func (l *logger) buildFile() ([]zapcore.Core, error) {
@@ -124,12 +370,7 @@ func (l *logger) buildFile() ([]zapcore.Core, error) {
 	}
 
 	cores := make([]zapcore.Core, 0, 1)
-	var enc zapcore.Encoder
-	if l.opt.encoder.IsConsole() {
-		enc = zapcore.NewConsoleEncoder(l.opt.encoderConfig)
-	} else {
-		enc = zapcore.NewJSONEncoder(l.opt.encoderConfig)
-	}
+	enc := l.buildFileEncoder()
 
 	syncerRolling, err := l.createOutput(l.opt.filename)
 
@@ -137,59 +378,67 @@ func (l *logger) buildFile() ([]zapcore.Core, error) {
 		return nil, err
 	}
 
-	cores = append(cores,
-		zapcore.NewCore(enc, syncerRolling, l.atomicLevel),
-	)
-
 	l._writeSyncers = append(l._writeSyncers, []zapcore.WriteSyncer{syncerRolling}...)
 
+	core, err := l.newSequenceCore(zapcore.NewCore(enc, syncerRolling, l.atomicLevel), l.opt.filename, make(map[string]*sequence))
+	if err != nil {
+		return nil, err
+	}
+	cores = append(cores, newMonotonicCore(core, newMonotonicClock(l.opt)))
+
 	return cores, nil
 }
 
 func (l *logger) buildFiles() ([]zapcore.Core, error) {
+	if err := l.Sync(); err != nil {
+		return nil, err
+	}
+
 	var (
-		err   error
-		cores = make([]zapcore.Core, 0, 5)
-		syncerRollingDebug, syncerRollingInfo, syncerRollingWarn,
-		syncerRollingError, syncerRollingFatal zapcore.WriteSyncer
+		enc     = l.buildFileEncoder()
+		cores   = make([]zapcore.Core, 0, len(levels))
+		syncers = make(map[string]zapcore.WriteSyncer, len(levels))
+		clocks  = make(map[string]*monotonicClock, len(levels))
+		seqs    = make(map[string]*sequence, len(levels))
 	)
 
-	if err = l.Sync(); err != nil {
-		return nil, err
+	if l.opt.singleFile != "" {
+		syncer, err := l.createOutput(l.opt.singleFile)
+		if err != nil {
+			return nil, err
+		}
+		l._writeSyncers = append(l._writeSyncers, syncer)
+		core, err := l.newSequenceCore(zapcore.NewCore(enc, syncer, l.atomicLevel), l.opt.singleFile, seqs)
+		if err != nil {
+			return nil, err
+		}
+		return append(cores, newMonotonicCore(core, newMonotonicClock(l.opt))), nil
 	}
 
-	syncerRollingDebug, err = l.createOutput(debugFilename)
-	if err != nil {
-		return nil, err
-	}
-	syncerRollingInfo, err = l.createOutput(infoFilename)
-	if err != nil {
-		return nil, err
-	}
-	syncerRollingWarn, err = l.createOutput(warnFilename)
-	if err != nil {
-		return nil, err
-	}
-	syncerRollingError, err = l.createOutput(errorFilename)
-	if err != nil {
-		return nil, err
-	}
-	syncerRollingFatal, err = l.createOutput(fatalFilename)
-	if err != nil {
-		return nil, err
+	// levels sharing a file name share the rolling file
+	for _, lv := range levels {
+		if !l.opt.hasLevelFile(lv) {
+			continue
+		}
+		filename := l.opt.levelFilename(lv)
+		syncer, ok := syncers[filename]
+		if !ok {
+			var err error
+			if syncer, err = l.createOutput(filename); err != nil {
+				return nil, err
+			}
+			syncers[filename] = syncer
+			clocks[filename] = newMonotonicClock(l.opt)
+			l._writeSyncers = append(l._writeSyncers, syncer)
+		}
+		core, err := l.newSequenceCore(zapcore.NewCore(enc, syncer, l.LevelEnablerFunc(lv.unmarshalZapLevel())), filename, seqs)
+		if err != nil {
+			return nil, err
+		}
+		core = newMonotonicCore(core, clocks[filename])
+		cores = append(cores, &levelCore{Core: core, match: levelMatcher(lv.unmarshalZapLevel())})
 	}
 
-	enc := l.buildEncoder(l.opt)
-	cores = append(cores,
-		zapcore.NewCore(enc, syncerRollingDebug, l.LevelEnablerFunc(zap.DebugLevel)),
-		zapcore.NewCore(enc, syncerRollingInfo, l.LevelEnablerFunc(zap.InfoLevel)),
-		zapcore.NewCore(enc, syncerRollingWarn, l.LevelEnablerFunc(zap.WarnLevel)),
-		zapcore.NewCore(enc, syncerRollingError, l.LevelEnablerFunc(zap.ErrorLevel)),
-		zapcore.NewCore(enc, syncerRollingFatal, l.LevelEnablerFunc(zap.FatalLevel)),
-	)
-
-	l._writeSyncers = append(l._writeSyncers, []zapcore.WriteSyncer{syncerRollingDebug, syncerRollingInfo, syncerRollingWarn, syncerRollingError, syncerRollingFatal}...)
-
 	return cores, nil
 }
 
@@ -198,12 +447,62 @@ func (l *logger) createOutput(filename string) (zapcore.WriteSyncer, error) {
 		return nil, ErrLogPathNotSet
 	}
 
-	rollingFile, err := NewRollingFile(path.Join(l.opt.basePath, filename), HourlyRolling)
+	rotator := l.opt.rotator
+	if rotator == nil {
+		rotator = l.newRollingFile
+	}
+	w, err := rotator(path.Join(l.opt.basePath, filename))
 	if err != nil {
 		return nil, err
 	}
+	return l.bufferOutput(w, true), nil
+}
 
-	return zapcore.AddSync(rollingFile), nil
+// newRollingFile is the default Rotator, creating a RollingFile configured by
+// the options of the logger.
+func (l *logger) newRollingFile(path string) (RotatingWriter, error) {
+	rollingFile, err := NewRollingFile(path, l.opt.rollingFormatOf(path))
+	if err != nil {
+		return nil, err
+	}
+	rollingFile.SetCompression(l.opt.compression, l.opt.compressionLevel)
+	rollingFile.SetCompressionDelay(l.opt.compressionDelay)
+	rollingFile.SetFileExt(l.opt.fileExt)
+	rollingFile.SetFileMode(l.opt.fileMode)
+	rollingFile.SetDirMode(l.opt.dirMode)
+	rollingFile.SetSymlink(l.opt.symlink)
+	rollingFile.SetFlatLayout(l.opt.flatLayout)
+	rollingFile.SetFilenameTemplate(l.opt.filenameTemplate)
+	rollingFile.SetPreallocate(l.opt.preallocSize)
+	rollingFile.SetHeader(l.opt.fileHeader)
+	rollingFile.SetManifest(l.opt.manifest)
+	rollingFile.SetMaxTotalSize(l.opt.maxTotalSize)
+	rollingFile.SetWriteRateLimit(l.opt.writeRateLimit)
+	rollingFile.SetFlushThreshold(l.opt.flushThreshold)
+	rollingFile.SetOverflowPolicy(l.opt.overflowPolicy)
+	rollingFile.SetErrorHandler(l.opt.errorHandler)
+	rollingFile.SetDiskFullFallback(l.opt.diskFullFallback)
+	rollingFile.SetFileLock(l.opt.fileLock)
+	rollingFile.SetOnRotated(l.opt.onRotated)
+	rollingFile.SetOnBeforeRotate(l.opt.beforeRotate)
+	rollingFile.SetOnAfterRotate(l.opt.afterRotate)
+	rollingFile.SetOnPressure(l.opt.onPressure)
+	rollingFile.SetRollingFunc(l.opt.rollingFunc)
+	rollingFile.SetMaxSize(l.opt.maxSize)
+	rollingFile.SetLocation(l.opt.rollingLocation)
+	rollingFile.SetFlushInterval(l.opt.flushInterval)
+	rollingFile.SetSyncPolicy(l.opt.syncPolicy, l.opt.syncInterval)
+	rollingFile.SetWatchInterval(l.opt.watchInterval)
+	rollingFile.SetLazyFlush(l.opt.lazyFlush)
+	rollingFile.SetClock(l.opt.clock)
+	if l.opt.bufferPoolSize > 0 {
+		rollingFile.SetBufferPoolSize(l.opt.bufferPoolSize)
+	}
+	if l.opt.directWrite {
+		rollingFile.SetDirectWrite(true, l.opt.directSync)
+	}
+
+	return rollingFile, nil
 }
 
 func (l *logger) Clone() *logger {
@@ -211,68 +510,90 @@ func (l *logger) Clone() *logger {
 	return &_copy
 }
 
+// Init applies options and swaps the outputs atomically, superseded outputs
+// are closed. Loggers sharing the outputs of l write to the new outputs too,
+// with the fields of the new options, and keep the fields they added and
+// their caller skip.
 func (l *logger) Init(opts ...Option) error {
-	// process options
-	for _, o := range opts {
-		o(&l.opt)
-	}
-
-	return nil
+	return l.rebuild(opts...)
 }
 
 func (l *logger) SetLevel(lv Level) {
-	l.opt.level = lv
 	l.atomicLevel.SetLevel(lv.unmarshalZapLevel())
 }
 
+// Options returns the options of the current outputs, with the current level.
 func (l *logger) Options() Options {
-	return l.opt
+	opt := l.outputs().opt
+	opt.level = levelFromZap(l.atomicLevel.Level())
+	return opt
 }
 
+// WithContext returns a copy of l with its context changed to ctx, a nil ctx
+// is context.Background(). The copy keeps the fields and caller skip of l, and
+// shares its level and outputs: SetLevel, Init and Close of either apply to
+// both. Loggers derived from the copy by WithFields, WithoutFields, Namespace
+// and WithCallDepth keep ctx.
 func (l *logger) WithContext(ctx context.Context) Logger {
-	logger := &logger{
-		ctx:         ctx,
-		opt:         l.opt,
-		atomicLevel: l.atomicLevel,
-		base:        l.base.WithOptions(zap.AddCallerSkip(0)),
+	if ctx == nil {
+		ctx = context.Background()
 	}
+	// the copy shares the bases of l, its fields being the same
+	logger := l.Clone()
+	logger.ctx = ctx
 	return logger
 }
 
 func (l *logger) WithFields(fields map[string]interface{}) Logger {
-	return &logger{
-		opt:         l.opt,
-		atomicLevel: l.atomicLevel,
-		base:        l.base.With(CopyFields(fields)...).WithOptions(zap.AddCallerSkip(0)),
+	logger := l.derive()
+	ctx := l.ctx
+	if ctx == nil {
+		ctx = context.Background()
 	}
+	// values are resolved once, when the logger is derived
+	logger.with = l.outputs().opt.interner.internFields(CopyFields(bindFields(ctx, fields)))
+	return logger
 }
 
-func (l *logger) WithCallDepth(callDepth int) Logger {
-	return &logger{
-		opt:         l.opt,
-		atomicLevel: l.atomicLevel,
-		base:        l.base.WithOptions(zap.AddCallerSkip(callDepth)),
+func (l *logger) WithoutFields(keys ...string) Logger {
+	logger := l.derive()
+	logger.without = append([]string{}, keys...)
+	return logger
+}
+
+func (l *logger) ReplaceFields(fields map[string]interface{}) Logger {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
 	}
+	return l.WithoutFields(keys...).WithFields(fields)
 }
 
-// Debug uses fmt.Sprint to construct and log a message.
-func (l *logger) Debug(args ...interface{}) {
-	l.log(DebugLevel, "", args, nil)
+func (l *logger) Namespace(name string) Logger {
+	logger := l.derive()
+	logger.with = []zap.Field{zap.Namespace(name)}
+	return logger
+}
+
+func (l *logger) WithCallDepth(callDepth int) Logger {
+	logger := l.derive()
+	logger.callDepth = callDepth
+	return logger
 }
 
 // Info uses fmt.Sprint to construct and log a message.
 func (l *logger) Info(args ...interface{}) {
-	l.log(InfoLevel, "", args, nil)
+	l.log(l.ctx, InfoLevel, "", args, nil)
 }
 
 // Warn uses fmt.Sprint to construct and log a message.
 func (l *logger) Warn(args ...interface{}) {
-	l.log(WarnLevel, "", args, nil)
+	l.log(l.ctx, WarnLevel, "", args, nil)
 }
 
 // Error uses fmt.Sprint to construct and log a message.
 func (l *logger) Error(args ...interface{}) {
-	l.log(ErrorLevel, "", args, nil)
+	l.log(l.ctx, ErrorLevel, "", args, nil)
 }
 
 // Fatal uses fmt.Sprint to construct and log a message, then calls os.Exit.
@@ -282,27 +603,22 @@ func (l *logger) Error(args ...interface{}) {
 // 临时文件或者目录不会被移除；
 // 不要使用 fatal 记录日志，而是向调用者返回错误。如果错误一直持续到 main.main。main.main 那就是在退出之前做处理任何清理操作的正确位置。
 func (l *logger) Fatal(args ...interface{}) {
-	l.log(FatalLevel, "", args, nil)
-}
-
-// Debugf uses fmt.Sprintf to log a templated message.
-func (l *logger) Debugf(template string, args ...interface{}) {
-	l.log(DebugLevel, template, args, nil)
+	l.log(l.ctx, FatalLevel, "", args, nil)
 }
 
 // Infof uses fmt.Sprintf to log a templated message.
 func (l *logger) Infof(template string, args ...interface{}) {
-	l.log(InfoLevel, template, args, nil)
+	l.log(l.ctx, InfoLevel, template, args, nil)
 }
 
 // Warnf uses fmt.Sprintf to log a templated message.
 func (l *logger) Warnf(template string, args ...interface{}) {
-	l.log(WarnLevel, template, args, nil)
+	l.log(l.ctx, WarnLevel, template, args, nil)
 }
 
 // Errorf uses fmt.Sprintf to log a templated message.
 func (l *logger) Errorf(template string, args ...interface{}) {
-	l.log(ErrorLevel, template, args, nil)
+	l.log(l.ctx, ErrorLevel, template, args, nil)
 }
 
 // Fatalf uses fmt.Sprintf to log a templated message, then calls os.Exit.
@@ -312,35 +628,25 @@ func (l *logger) Errorf(template string, args ...interface{}) {
 // 临时文件或者目录不会被移除；
 // 不要使用 fatal 记录日志，而是向调用者返回错误。如果错误一直持续到 main.main。main.main 那就是在退出之前做处理任何清理操作的正确位置。
 func (l *logger) Fatalf(template string, args ...interface{}) {
-	l.log(FatalLevel, template, args, nil)
-}
-
-// Debugw logs a message with some additional context. The variadic key-value
-// pairs are treated as they are in With.
-//
-// When debug-level logging is disabled, this is much faster than
-//
-//	s.With(keysAndValues).Debug(msg)
-func (l *logger) Debugw(msg string, keysAndValues ...interface{}) {
-	l.log(DebugLevel, msg, nil, keysAndValues)
+	l.log(l.ctx, FatalLevel, template, args, nil)
 }
 
 // Infow logs a message with some additional context. The variadic key-value
 // pairs are treated as they are in With.
 func (l *logger) Infow(msg string, keysAndValues ...interface{}) {
-	l.log(InfoLevel, msg, nil, keysAndValues)
+	l.log(l.ctx, InfoLevel, msg, nil, keysAndValues)
 }
 
 // Warnw logs a message with some additional context. The variadic key-value
 // pairs are treated as they are in With.
 func (l *logger) Warnw(msg string, keysAndValues ...interface{}) {
-	l.log(WarnLevel, msg, nil, keysAndValues)
+	l.log(l.ctx, WarnLevel, msg, nil, keysAndValues)
 }
 
 // Errorw logs a message with some additional context. The variadic key-value
 // pairs are treated as they are in With.
 func (l *logger) Errorw(msg string, keysAndValues ...interface{}) {
-	l.log(ErrorLevel, msg, nil, keysAndValues)
+	l.log(l.ctx, ErrorLevel, msg, nil, keysAndValues)
 }
 
 // Fatalw logs a message with some additional context, then calls os.Exit. The
@@ -351,16 +657,16 @@ func (l *logger) Errorw(msg string, keysAndValues ...interface{}) {
 // 临时文件或者目录不会被移除；
 // 不要使用 fatal 记录日志，而是向调用者返回错误。如果错误一直持续到 main.main。main.main 那就是在退出之前做处理任何清理操作的正确位置。
 func (l *logger) Fatalw(msg string, keysAndValues ...interface{}) {
-	l.log(FatalLevel, msg, nil, keysAndValues)
+	l.log(l.ctx, FatalLevel, msg, nil, keysAndValues)
 }
 
 func (l *logger) Sync() error {
-	if l.base != nil {
-		return l.base.Sync()
+	if l.state != nil {
+		return l.base(l.outputs()).base.Sync()
 	}
 
 	for _, w := range l._writeSyncers {
-		r, ok := w.(*RollingFile)
+		r, ok := unbuffered(w).(*RollingFile)
 		if ok {
 			r.Close()
 		}
@@ -369,6 +675,93 @@ func (l *logger) Sync() error {
 	return nil
 }
 
+// Close persists the sequences of WithSequence, flushes buffered entries and
+// closes every output opened by l, stopping their flush goroutines. Writers
+// passed in the options are left open. It returns ctx.Err() once ctx is done,
+// leaving the remaining outputs to finish in the background, so that
+// shutdown time stays bounded.
+func (l *logger) Close(ctx context.Context) error {
+	out := l.outputs()
+	var closers []io.Closer
+	for _, w := range out.writeSyncers {
+		if c, ok := w.(io.Closer); ok {
+			closers = append(closers, c)
+		}
+	}
+	closers = append(closers, out.closers...)
+
+	fns := make([]func() error, 0, len(out.sequences)+len(closers))
+	for _, s := range out.sequences {
+		fns = append(fns, s.sync)
+	}
+	for _, c := range closers {
+		fns = append(fns, c.Close)
+	}
+	return runParallel(ctx, fns)
+}
+
+// Flush writes buffered entries to every output, like Sync, but returns
+// ctx.Err() once ctx is done, leaving the remaining outputs to finish in the
+// background.
+func (l *logger) Flush(ctx context.Context) error {
+	out := l.outputs()
+	var fns []func() error
+	for _, w := range out.writeSyncers {
+		fns = append(fns, flushFunc(ctx, w))
+	}
+	for _, c := range out.closers {
+		fns = append(fns, flushFunc(ctx, c))
+	}
+	if out.opt.jsonCopy != nil {
+		fns = append(fns, flushFunc(ctx, out.opt.jsonCopy))
+	}
+	return runParallel(ctx, fns)
+}
+
+// flushFunc returns the function flushing the output o.
+func flushFunc(ctx context.Context, o interface{}) func() error {
+	switch w := o.(type) {
+	case interface{ Flush(context.Context) error }:
+		return func() error { return w.Flush(ctx) }
+	case interface{ Sync() error }:
+		return w.Sync
+	}
+	return func() error { return nil }
+}
+
+// runParallel runs fns in parallel and returns their errors once they
+// returned, or ctx.Err() once ctx is done.
+func runParallel(ctx context.Context, fns []func() error) error {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		err  error
+		done = make(chan struct{})
+	)
+	for _, fn := range fns {
+		wg.Add(1)
+		go func(fn func() error) {
+			defer wg.Done()
+			if e := fn(); e != nil {
+				mu.Lock()
+				err = multierr.Append(err, e)
+				mu.Unlock()
+			}
+		}(fn)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // getMessage format with Sprint, Sprintf, or neither.
 func getMessage(template string, fmtArgs []interface{}) string {
 	if len(fmtArgs) == 0 {
@@ -387,6 +780,19 @@ func getMessage(template string, fmtArgs []interface{}) string {
 	return fmt.Sprint(fmtArgs...)
 }
 
+// truncateMessage cuts msg to at most max bytes, at a rune boundary, and
+// appends a marker telling how many bytes were cut. max <= 0 keeps msg whole.
+func truncateMessage(msg string, max int) string {
+	if max <= 0 || len(msg) <= max {
+		return msg
+	}
+	n := max
+	for n > 0 && !utf8.RuneStart(msg[n]) {
+		n--
+	}
+	return msg[:n] + "...(truncated " + strconv.Itoa(len(msg)-n) + " bytes)"
+}
+
 type invalidPair struct {
 	position   int
 	key, value interface{}
@@ -414,7 +820,7 @@ const (
 	_nonStringKeyErrMsg = "Ignored key-value pairs with non-string keys."
 )
 
-func (l *logger) sweetenFields(args []interface{}) []zap.Field {
+func (b *loggerBase) sweetenFields(ctx context.Context, args []interface{}) []zap.Field {
 	if len(args) == 0 {
 		return nil
 	}
@@ -434,7 +840,7 @@ func (l *logger) sweetenFields(args []interface{}) []zap.Field {
 
 		// Make sure this element isn't a dangling key.
 		if i == len(args)-1 {
-			l.base.Error(_oddNumberErrMsg, zap.Any("ignored", args[i]))
+			b.reportInvalid(_oddNumberErrMsg, zap.Any("ignored", args[i]))
 			break
 		}
 
@@ -448,32 +854,108 @@ func (l *logger) sweetenFields(args []interface{}) []zap.Field {
 			}
 			invalid = append(invalid, invalidPair{i, key, val})
 		} else {
-			fields = append(fields, zap.Any(keyStr, val))
+			fields = append(fields, zap.Any(keyStr, Value(ctx, val)))
 		}
 		i += 2
 	}
 
 	// If we encountered any invalid key-value pairs, log an error.
 	if len(invalid) > 0 {
-		l.base.Error(_nonStringKeyErrMsg, zap.Array("invalid", invalid))
+		b.reportInvalid(_nonStringKeyErrMsg, zap.Array("invalid", invalid))
 	}
 	return fields
 }
 
-func (l *logger) log(level Level, template string, fmtArgs []interface{}, context []interface{}) {
-	bindValues(l.ctx, fmtArgs)
+// reportInvalid logs invalid key-value pairs, panicking in development mode.
+func (b *loggerBase) reportInvalid(msg string, field zap.Field) {
+	if b.outputs.opt.development {
+		b.base.DPanic(msg, field)
+		return
+	}
+	b.base.Error(msg, field)
+}
+
+// contextBackground is the context of entries logged without one, the
+// parameters of log shadow the context package.
+var contextBackground = context.Background()
+
+func (l *logger) log(ctx context.Context, level Level, template string, fmtArgs []interface{}, context []interface{}) {
+	if ctx == nil {
+		// loggers of New and the Ctx methods given nil
+		ctx = contextBackground
+	}
+	fmtArgs = bindValues(ctx, fmtArgs)
 	// If logging at this level is completely disabled, skip the overhead of
 	// string formatting.
-	if level < DebugLevel || !l.base.Core().Enabled(level.unmarshalZapLevel()) {
+	if level < DebugLevel {
 		return
 	}
+	out := l.acquire()
+	defer out.release()
+	b := l.base(out)
+	opt := &out.opt
+	base := b.base
+	if !base.Core().Enabled(level.unmarshalZapLevel()) {
+		if !opt.elevated(ctx, level) {
+			return
+		}
+		base = b.debugBase()
+	}
 
-	msg := getMessage(template, fmtArgs)
-	if ce := l.base.Check(level.unmarshalZapLevel(), msg); ce != nil {
-		ce.Write(l.sweetenFields(context)...)
+	msg := truncateMessage(getMessage(template, fmtArgs), opt.maxMessageSize)
+	if ce := base.Check(level.unmarshalZapLevel(), msg); ce != nil {
+		if ce.Entry.Caller.Defined && len(out.skipPackages) > 0 {
+			// resolved once for all the cores
+			ce.Entry.Caller = skipFrames(ce.Entry.Caller, out.skipPackages)
+		}
+		kv := ctxFields(ctx)
+		if kv = append(kv[:len(kv):len(kv)], scopeFields(ctx)...); len(kv) > 0 {
+			context = append(kv[:len(kv):len(kv)], context...)
+		}
+		fields := b.sweetenFields(ctx, context)
+		fields = append(fields, opt.baggageFields(ctx)...)
+		fields = append(fields, opt.contextKeyFields(ctx)...)
+		if opt.idGenerator != nil {
+			fields = append(fields, zap.String(opt.idKey, opt.idGenerator()))
+		}
+		if len(opt.middlewares) == 0 {
+			ce.Write(opt.withContext(ctx, fields)...)
+			return
+		}
+
+		written := false
+		write := chainMiddlewares(opt.middlewares, func(ent zapcore.Entry, fields []zapcore.Field) error {
+			ce.Entry = ent
+			ce.Write(opt.withContext(ctx, fields)...)
+			written = true
+			return nil
+		})
+		write(ce.Entry, fields)
+		if !written && level == FatalLevel {
+			// a middleware can drop a fatal entry, not its exit
+			l.exitDropped(opt, ce.Entry, fields)
+		}
 	}
 }
 
+// InfoCtx logs a message with some additional context like Infow, ctx is
+// used as by WithContext without cloning l.
+func (l *logger) InfoCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	l.log(ctx, InfoLevel, msg, nil, keysAndValues)
+}
+
+// WarnCtx logs a message with some additional context like Warnw, ctx is
+// used as by WithContext without cloning l.
+func (l *logger) WarnCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	l.log(ctx, WarnLevel, msg, nil, keysAndValues)
+}
+
+// ErrorCtx logs a message with some additional context like Errorw, ctx is
+// used as by WithContext without cloning l.
+func (l *logger) ErrorCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	l.log(ctx, ErrorLevel, msg, nil, keysAndValues)
+}
+
 func (l *logger) String() string {
 	return "zap"
 }
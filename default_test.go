@@ -1,11 +1,24 @@
 package logger
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 const (
@@ -109,7 +122,7 @@ func TestDefault_Warnw(t *testing.T) {
 }
 
 func TestDefault_WithCallDepth(t *testing.T) {
-	log := New(WithBasePath("../logs"), WithConsole(true))
+	log := New(WithBasePath(t.TempDir()), WithConsole(true))
 	log.WithCallDepth(0).Info(msg)
 }
 
@@ -121,7 +134,7 @@ func TestDefault_WithFields(t *testing.T) {
 }
 
 func TestDefault_createOutput(t *testing.T) {
-	log := New(WithBasePath("../logs"), WithConsole(true)).(*logger)
+	log := New(WithBasePath(t.TempDir()), WithConsole(true)).(*logger)
 	writeSyncer, err := log.createOutput(infoFilename)
 	if err != nil {
 		assert.Error(t, err)
@@ -132,12 +145,12 @@ func TestDefault_createOutput(t *testing.T) {
 }
 
 func TestDefault_log(t *testing.T) {
-	log := New(WithBasePath("../logs"), WithConsole(true)).(*logger)
-	log.log(DebugLevel, msg, nil, nil)
+	log := New(WithBasePath(t.TempDir()), WithConsole(true)).(*logger)
+	log.log(log.ctx, DebugLevel, msg, nil, nil)
 }
 
 func TestDefault_setUp(t *testing.T) {
-	log := New(WithBasePath("../logs"), WithConsole(true)).(*logger)
+	log := New(WithBasePath(t.TempDir()), WithConsole(true)).(*logger)
 	if err := log.build(); err != nil {
 		assert.Error(t, err)
 	}
@@ -145,7 +158,7 @@ func TestDefault_setUp(t *testing.T) {
 
 func TestSlowLogger(t *testing.T) {
 	slow := New(
-		WithBasePath("./testdata/logs"),
+		WithBasePath(t.TempDir()),
 		WithConsole(true),
 		WithDisableDisk(false),
 		WithLevel(InfoLevel),
@@ -165,7 +178,7 @@ func TestSlowLogger(t *testing.T) {
 }
 
 func TestFileLogger(t *testing.T) {
-	stat := New(WithBasePath("../logs"),
+	stat := New(WithBasePath(t.TempDir()),
 		WithConsole(true),
 		WithDisableDisk(false),
 		WithFilename("stat"),
@@ -180,7 +193,7 @@ func TestFileLogger(t *testing.T) {
 }
 
 func TestLogger(t *testing.T) {
-	log := New(WithBasePath("../logs"),
+	log := New(WithBasePath(t.TempDir()),
 		WithConsole(true),
 		WithDisableDisk(true),
 		WithFields(map[string]interface{}{
@@ -192,3 +205,557 @@ func TestLogger(t *testing.T) {
 
 	log.Debug(msg)
 }
+
+// newObservedLogger returns a logger whose entries are recorded in memory.
+func newObservedLogger(opts ...Option) (*logger, *observer.ObservedLogs) {
+	opt := newOptions(opts...)
+	core, logs := observer.New(zap.DebugLevel)
+	state := &outputState{}
+	state.current.Store(&outputs{
+		opt:    opt,
+		core:   core,
+		root:   zap.New(core),
+		fields: CopyFields(opt.fields),
+	})
+	return &logger{
+		atomicLevel: zap.NewAtomicLevelAt(opt.level.unmarshalZapLevel()),
+		state:       state,
+		bases:       new(atomic.Value),
+	}, logs
+}
+
+func TestDefault_WithoutFields(t *testing.T) {
+	log, logs := newObservedLogger(WithFields(map[string]interface{}{
+		"app_id":    "mt",
+		"component": "parent",
+	}))
+
+	log.WithoutFields("app_id").Info(msg)
+	assert.Equal(t, map[string]interface{}{"component": "parent"}, logs.TakeAll()[0].ContextMap())
+
+	log.ReplaceFields(map[string]interface{}{"component": "child"}).Info(msg)
+	assert.Equal(t, map[string]interface{}{"app_id": "mt", "component": "child"}, logs.TakeAll()[0].ContextMap())
+}
+
+func TestDefault_Namespace(t *testing.T) {
+	log, logs := newObservedLogger(WithFields(map[string]interface{}{"app_id": "mt"}))
+
+	db := log.Namespace("db").WithFields(map[string]interface{}{"table": "users"})
+	db.(Namespacer).Namespace("tx").Infow(msg, "id", 1)
+	assert.Equal(t, map[string]interface{}{
+		"app_id": "mt",
+		"db": map[string]interface{}{
+			"table": "users",
+			"tx":    map[string]interface{}{"id": int64(1)},
+		},
+	}, logs.TakeAll()[0].ContextMap())
+}
+
+func TestLevelFilenames(t *testing.T) {
+	dir := t.TempDir()
+	log := New(
+		WithBasePath(dir),
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithRollingFunc(func(name string, t time.Time) (string, string) {
+			return "", name
+		}),
+		WithLevelFilenames(map[Level]string{
+			InfoLevel:  "app",
+			ErrorLevel: "err",
+			FatalLevel: "err",
+		}),
+	).(*logger)
+	assert.Len(t, log._writeSyncers, 4)
+
+	log.Info(msg)
+	log.Error(msg)
+	for _, w := range log._writeSyncers {
+		w.Sync()
+	}
+
+	for _, name := range []string{"app.log", "err.log"} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		assert.NoError(t, err)
+	}
+}
+
+func TestLevelFiles(t *testing.T) {
+	dir := t.TempDir()
+	log := New(
+		WithBasePath(dir),
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithLevel(DebugLevel),
+		WithRollingFunc(func(name string, t time.Time) (string, string) {
+			return "", name
+		}),
+		WithLevelFiles(InfoLevel, ErrorLevel),
+	).(*logger)
+	assert.Len(t, log._writeSyncers, 2)
+
+	log.Debug(msg)
+	log.Info(msg)
+	log.Error(msg)
+	assert.NoError(t, log.Sync())
+
+	for _, name := range []string{"info.log", "error.log"} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		assert.NoError(t, err)
+	}
+	_, err := os.Stat(filepath.Join(dir, "debug.log"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = NewWithError(WithLevelFiles(Level(42)))
+	assert.Error(t, err)
+}
+
+func TestDualFormat(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(WithConsole(true), WithDisableDisk(true), WithEncoder(JsonEncoder), WithDualFormat(&buf))
+
+	log.Infow(msg, "age", 23)
+	var m map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	assert.Equal(t, "info", m["level"])
+	assert.Equal(t, msg, m["msg"])
+	assert.Equal(t, float64(23), m["age"])
+}
+
+type memRotator struct {
+	bytes.Buffer
+	path string
+}
+
+func (w *memRotator) Sync() error  { return nil }
+func (w *memRotator) Close() error { return nil }
+
+func TestRotator(t *testing.T) {
+	var writers []*memRotator
+	log := New(
+		WithBasePath("logs"),
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithFilename("app"),
+		WithRotator(func(path string) (RotatingWriter, error) {
+			w := &memRotator{path: path}
+			writers = append(writers, w)
+			return w, nil
+		}),
+	)
+
+	log.Info(msg)
+	assert.Len(t, writers, 1)
+	assert.Equal(t, filepath.Join("logs", "app"), writers[0].path)
+	assert.Contains(t, writers[0].String(), msg)
+}
+
+func TestSingleFile(t *testing.T) {
+	dir := t.TempDir()
+	log := New(
+		WithBasePath(dir),
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithLevel(DebugLevel),
+		WithRollingFunc(func(name string, t time.Time) (string, string) {
+			return "", name
+		}),
+		WithSingleFile("app"),
+	).(*logger)
+	assert.Len(t, log._writeSyncers, 1)
+
+	log.Debug(msg)
+	log.Error(msg)
+	assert.NoError(t, log.Sync())
+
+	b, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), `"level":"error"`)
+}
+
+type blockingRotator struct {
+	memRotator
+	release chan struct{}
+}
+
+func (w *blockingRotator) Close() error {
+	<-w.release
+	return nil
+}
+
+type blockingSyncRotator struct {
+	memRotator
+	release chan struct{}
+}
+
+func (w *blockingSyncRotator) Sync() error {
+	<-w.release
+	return nil
+}
+
+func TestFlush(t *testing.T) {
+	dir := t.TempDir()
+	log := New(
+		WithBasePath(dir),
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithRollingFunc(func(name string, t time.Time) (string, string) {
+			return "", name
+		}),
+		WithSingleFile("app"),
+	)
+	defer log.(Closer).Close(context.Background())
+
+	log.Info(msg)
+	assert.NoError(t, log.(Flusher).Flush(context.Background()))
+	b, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), msg)
+
+	release := make(chan struct{})
+	defer close(release)
+	log = New(
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithFilename("app"),
+		WithRotator(func(path string) (RotatingWriter, error) {
+			return &blockingSyncRotator{release: release}, nil
+		}),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, log.(Flusher).Flush(ctx), context.DeadlineExceeded)
+}
+
+func TestClose(t *testing.T) {
+	dir := t.TempDir()
+	log := New(
+		WithBasePath(dir),
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithRollingFunc(func(name string, t time.Time) (string, string) {
+			return "", name
+		}),
+		WithSingleFile("app"),
+	)
+
+	log.Info(msg)
+	assert.NoError(t, log.(Closer).Close(context.Background()))
+	b, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), msg)
+
+	release := make(chan struct{})
+	defer close(release)
+	log = New(
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithFilename("app"),
+		WithRotator(func(path string) (RotatingWriter, error) {
+			return &blockingRotator{release: release}, nil
+		}),
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, log.(Closer).Close(ctx), context.DeadlineExceeded)
+}
+
+func TestColor(t *testing.T) {
+	defer func(fn func(*os.File) bool) { isTerminal = fn }(isTerminal)
+	log := New(WithConsole(true), WithDisableDisk(true), WithEncoder(ConsoleEncoder), WithColor(true)).(*logger)
+
+	for _, tty := range []bool{true, false} {
+		isTerminal = func(*os.File) bool { return tty }
+		buf, err := log.buildConsoleEncoder().EncodeEntry(zapcore.Entry{Level: zapcore.InfoLevel, Message: msg}, nil)
+		assert.NoError(t, err)
+		if tty {
+			assert.Contains(t, buf.String(), "\x1b[34mINFO\x1b[0m")
+		} else {
+			assert.Contains(t, buf.String(), "\tinfo\t")
+		}
+	}
+}
+
+func TestStacktraceLevel(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf), WithStacktraceLevel(ErrorLevel))
+
+	for _, fn := range []func(...interface{}){log.Warn, log.Error} {
+		buf.Reset()
+		fn(msg)
+		var m map[string]interface{}
+		assert.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+		if m["level"] == "error" {
+			assert.Contains(t, m["stack"], "TestStacktraceLevel")
+		} else {
+			assert.NotContains(t, m, "stack")
+		}
+	}
+}
+
+func TestDevelopment(t *testing.T) {
+	log := New(WithDevelopment(), WithDisableDisk(true))
+	opt := log.Options()
+	assert.Equal(t, Level(DebugLevel), opt.level)
+	assert.Equal(t, ConsoleEncoder, opt.encoder)
+	assert.True(t, opt.console)
+	assert.True(t, opt.color)
+	assert.Equal(t, Level(WarnLevel), opt.stacktraceLevel)
+
+	// dangling keys panic instead of being reported
+	assert.Panics(t, func() { log.Infow(msg, "dangling") })
+	assert.NotPanics(t, func() { New(WithDisableDisk(true)).Infow(msg, "dangling") })
+}
+
+func TestPresets(t *testing.T) {
+	opt := New(Production()).Options()
+	assert.Equal(t, Level(InfoLevel), opt.level)
+	assert.Equal(t, JsonEncoder, opt.encoder)
+	assert.Equal(t, Level(ErrorLevel), opt.stacktraceLevel)
+	assert.Equal(t, time.Second, opt.sampling.tick)
+
+	opt = New(Production(), Development()).Options()
+	assert.Equal(t, Level(DebugLevel), opt.level)
+	assert.Equal(t, Level(WarnLevel), opt.stacktraceLevel)
+	assert.Zero(t, opt.sampling.tick)
+}
+
+func TestSampling(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf), WithSampling(time.Minute, 2, 0))
+
+	for i := 0; i < 5; i++ {
+		log.Info(msg)
+	}
+	log.Warn(msg)
+	assert.Equal(t, 3, strings.Count(buf.String(), "\n"))
+
+	_, err := NewWithError(WithSampling(-time.Second, 1, 1))
+	assert.Error(t, err)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk on fire") }
+
+func TestErrorOutput(t *testing.T) {
+	var errOut bytes.Buffer
+	log := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(failingWriter{}), WithErrorOutput(zapcore.AddSync(&errOut)))
+
+	log.Info(msg)
+	assert.Contains(t, errOut.String(), "disk on fire")
+}
+
+func TestExitFunc(t *testing.T) {
+	dir := t.TempDir()
+	code := 0
+	log := New(
+		WithBasePath(dir),
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithRollingFunc(func(name string, t time.Time) (string, string) {
+			return "", name
+		}),
+		WithSingleFile("app"),
+		WithExitFunc(func(c int) { code = c }),
+	)
+	defer log.(Closer).Close(context.Background())
+
+	log.Fatal(msg)
+	assert.Equal(t, 1, code)
+	// flushed before exiting
+	b, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(b), msg)
+}
+
+func TestClock(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Date(2020, 1, 1, 10, 59, 59, 0, time.UTC)
+	log := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf), WithClock(&fakeClock{now: now}))
+
+	log.Info(msg)
+	var m map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	ts, err := time.Parse("2006-01-02T15:04:05.000Z0700", m["ts"].(string))
+	assert.NoError(t, err)
+	assert.True(t, now.Equal(ts))
+}
+
+type fatalHookFunc func(*zapcore.CheckedEntry, []zapcore.Field)
+
+func (f fatalHookFunc) OnWrite(ce *zapcore.CheckedEntry, fields []zapcore.Field) { f(ce, fields) }
+
+func TestFatalHook(t *testing.T) {
+	var calls []string
+	log := New(
+		WithConsole(false),
+		WithDisableDisk(true),
+		WithDualFormat(io.Discard),
+		WithFatalHook(fatalHookFunc(func(ce *zapcore.CheckedEntry, fields []zapcore.Field) {
+			calls = append(calls, "hook "+ce.Message)
+		})),
+		WithExitFunc(func(code int) { calls = append(calls, "exit") }),
+	)
+
+	log.Fatal(msg)
+	assert.Equal(t, []string{"hook " + msg, "exit"}, calls)
+}
+
+func TestRollingFormat(t *testing.T) {
+	log := New(
+		WithBasePath(t.TempDir()),
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithRollingFormat(DailyRolling),
+		WithLevelRollingFormats(map[Level]RollingFormat{DebugLevel: HourlyRolling}),
+	).(*logger)
+	defer log.Close(context.Background())
+
+	formats := make(map[string]RollingFormat)
+	for _, w := range log._writeSyncers {
+		r := w.(*RollingFile)
+		formats[filepath.Base(r.basePath)] = r.rolling
+	}
+	assert.Equal(t, RollingFormat(HourlyRolling), formats[debugFilename])
+	assert.Equal(t, RollingFormat(DailyRolling), formats[infoFilename])
+	assert.Equal(t, RollingFormat(DailyRolling), formats[fatalFilename])
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	log, logs := newObservedLogger(WithMaxMessageSize(5))
+
+	log.Info("hello")
+	log.Infof("%s there", "hello")
+	log.Info("abcdé")
+	var msgs []string
+	for _, e := range logs.AllUntimed() {
+		msgs = append(msgs, e.Message)
+	}
+	// é takes two bytes and isn't split
+	assert.Equal(t, []string{"hello", "hello...(truncated 6 bytes)", "abcd...(truncated 2 bytes)"}, msgs)
+}
+
+func TestConsoleAndFileEncoders(t *testing.T) {
+	log := New(WithDisableDisk(true), WithEncoder(JsonEncoder), WithConsoleEncoder(ConsoleEncoder)).(*logger)
+
+	ent := zapcore.Entry{Level: zapcore.InfoLevel, Message: msg}
+	buf, err := log.buildConsoleEncoder().EncodeEntry(ent, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "\tinfo\t"+msg)
+	buf, err = log.buildFileEncoder().EncodeEntry(ent, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `"msg":"hello there"`)
+
+	log = New(WithDisableDisk(true), WithFileEncoder(ConsoleEncoder)).(*logger)
+	buf, err = log.buildFileEncoder().EncodeEntry(ent, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "\tinfo\t"+msg)
+}
+
+func TestEncoderFactory(t *testing.T) {
+	factory := func(cfg zapcore.EncoderConfig) zapcore.Encoder {
+		cfg.MessageKey = "message"
+		return zapcore.NewJSONEncoder(cfg)
+	}
+	log := New(WithDisableDisk(true), WithEncoderFactory(factory), WithConsoleEncoder(ConsoleEncoder)).(*logger)
+
+	ent := zapcore.Entry{Level: zapcore.InfoLevel, Message: msg}
+	buf, err := log.buildFileEncoder().EncodeEntry(ent, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `"message":"hello there"`)
+	buf, err = log.buildConsoleEncoder().EncodeEntry(ent, nil)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "\tinfo\t"+msg)
+}
+
+func TestRuntimeFields(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf), WithRuntimeFields("1.2.3"))
+
+	log.Info(msg)
+	var m map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	host, _ := os.Hostname()
+	assert.Equal(t, host, m["hostname"])
+	assert.Equal(t, float64(os.Getpid()), m["pid"])
+	assert.Equal(t, runtime.Version(), m["go_version"])
+	assert.Equal(t, "1.2.3", m["app_version"])
+}
+
+func TestLineEnding(t *testing.T) {
+	var buf bytes.Buffer
+	log := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf), WithLineEnding("\r\n"))
+
+	log.Info(msg)
+	log.Info(msg)
+	assert.Equal(t, 2, strings.Count(buf.String(), "}\r\n"))
+}
+
+func TestZapOptions(t *testing.T) {
+	var entries []string
+	log := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(io.Discard), WithZapOptions(zap.Hooks(func(ent zapcore.Entry) error {
+		entries = append(entries, ent.Message)
+		return nil
+	})))
+
+	log.Info(msg)
+	assert.Equal(t, []string{msg}, entries)
+
+	// they override the options of this package
+	var buf bytes.Buffer
+	log = New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf),
+		WithStacktraceLevel(WarnLevel), WithZapOptions(zap.AddStacktrace(zap.ErrorLevel)))
+	log.Warn(msg)
+	assert.NotContains(t, buf.String(), `"stack"`)
+	log.Error(msg)
+	assert.Contains(t, buf.String(), `"stack"`)
+}
+
+func TestSetDefaultOptions(t *testing.T) {
+	var defaultBuf, buf bytes.Buffer
+	prev := DefaultLogger
+	DefaultLogger = New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&defaultBuf))
+	defer func() {
+		defaultOptions = nil
+		DefaultLogger = prev
+	}()
+
+	assert.NoError(t, SetDefaultOptions(WithFields(map[string]interface{}{"team": "infra"})))
+	Info(msg)
+	assert.Contains(t, defaultBuf.String(), `"team":"infra"`)
+
+	log := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf))
+	log.Info(msg)
+	assert.Contains(t, buf.String(), `"team":"infra"`)
+
+	// options of the logger override the defaults
+	buf.Reset()
+	log = New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf), WithFields(map[string]interface{}{"team": "web"}))
+	log.Info(msg)
+	assert.Contains(t, buf.String(), `"team":"web"`)
+}
+
+func TestBufferedOutput(t *testing.T) {
+	w := &closeRecorder{}
+	log := New(
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithFilename("app"),
+		WithRotator(func(path string) (RotatingWriter, error) { return w, nil }),
+		WithBufferedOutput(0, time.Hour),
+	)
+
+	log.Info(msg)
+	assert.Zero(t, w.Len())
+	assert.NoError(t, log.Sync())
+	assert.Contains(t, w.String(), msg)
+
+	log.Info(msg)
+	assert.NoError(t, log.(Closer).Close(context.Background()))
+	assert.Equal(t, 2, strings.Count(w.String(), msg))
+	assert.True(t, w.closed)
+
+	_, err := NewWithError(WithBufferedOutput(-1, 0))
+	assert.Error(t, err)
+}
@@ -0,0 +1,96 @@
+package logger
+
+import (
+	"fmt"
+	"time"
+)
+
+// TuningReport : Write amplification of a RollingFile over a window, with
+// suggestions to tune it for its workload
+type TuningReport struct {
+	// Window is the duration measured.
+	Window time.Duration
+	// BytesAccepted is the bytes accepted by Write.
+	BytesAccepted int64
+	// BytesWritten is the bytes written to files.
+	BytesWritten int64
+	// FileWrites is the number of writes to files.
+	FileWrites int64
+	// Syncs is the number of fsyncs.
+	Syncs int64
+	// Dropped is the number of writes dropped.
+	Dropped int64
+	// Spilled is the bytes spilled to the on-disk overflow queue.
+	Spilled int64
+	// AvgWriteSize is the average bytes per write to files.
+	AvgWriteSize float64
+	// Suggestions is the advice to tune the file, empty if it's fine.
+	Suggestions []string
+}
+
+const (
+	// writes and fsyncs per second beyond which batching is advised
+	tuneWritesPerSecond = 100
+	tuneSyncsPerSecond  = 10
+	// bytes written per second beyond which compression is advised
+	tuneCompressBytesPerSecond = 1 << 20
+)
+
+// Describe : Measure write amplification since the previous call, or since
+// the file was created, and suggest tuning of buffers, intervals and compression
+func (r *RollingFile) Describe() TuningReport {
+	stats := r.Stats()
+	now := time.Now()
+
+	r.describeMutex.Lock()
+	prev, since := r.describeStats, r.describeAt
+	r.describeStats, r.describeAt = stats, now
+	r.describeMutex.Unlock()
+
+	report := TuningReport{
+		Window:        now.Sub(since),
+		BytesAccepted: stats.BytesAccepted - prev.BytesAccepted,
+		BytesWritten:  stats.BytesWritten - prev.BytesWritten,
+		FileWrites:    stats.FileWrites - prev.FileWrites,
+		Syncs:         stats.Syncs - prev.Syncs,
+		Dropped:       stats.Dropped - prev.Dropped,
+		Spilled:       stats.Spilled - prev.Spilled,
+	}
+	if report.FileWrites > 0 {
+		report.AvgWriteSize = float64(report.BytesWritten) / float64(report.FileWrites)
+	}
+
+	r.mu.Lock()
+	threshold := r.flushThreshold
+	r.mu.Unlock()
+	r.rollMutex.RLock()
+	interval, policy, compression := r.flushInterval, r.syncPolicy, r.compression
+	r.rollMutex.RUnlock()
+
+	seconds := report.Window.Seconds()
+	if seconds <= 0 {
+		return report
+	}
+	if float64(report.FileWrites)/seconds > tuneWritesPerSecond && report.AvgWriteSize < float64(threshold)/2 {
+		report.Suggestions = append(report.Suggestions, fmt.Sprintf(
+			"%.0f writes/s averaging %.0f bytes: increase the flush interval (%s) to batch more per write",
+			float64(report.FileWrites)/seconds, report.AvgWriteSize, interval))
+	}
+	if float64(report.Syncs)/seconds > tuneSyncsPerSecond && policy != SyncPeriodically {
+		report.Suggestions = append(report.Suggestions, fmt.Sprintf(
+			"%.0f fsyncs/s: use SyncPeriodically to bound fsyncs", float64(report.Syncs)/seconds))
+	}
+	if report.Dropped > 0 {
+		report.Suggestions = append(report.Suggestions, fmt.Sprintf(
+			"%d writes dropped: increase the buffer pool size or use OverflowBlock or OverflowSpill", report.Dropped))
+	}
+	if report.Spilled > 0 {
+		report.Suggestions = append(report.Suggestions, fmt.Sprintf(
+			"%d bytes spilled to disk: increase the flush threshold (%d bytes) or the buffer pool size", report.Spilled, threshold))
+	}
+	if compression == NoCompression && float64(report.BytesWritten)/seconds > tuneCompressBytesPerSecond {
+		report.Suggestions = append(report.Suggestions, fmt.Sprintf(
+			"%.1f MB/s written: enable compression of rolled files", float64(report.BytesWritten)/seconds/(1<<20)))
+	}
+	return report
+}
@@ -0,0 +1,194 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"go.uber.org/multierr"
+)
+
+// evictMutex serializes evictions of rolling files sharing directories.
+var evictMutex sync.Mutex
+
+type evictFile struct {
+	path string
+	info os.FileInfo
+}
+
+// evictOldest removes the least recently modified files under dir owned by a
+// rolling file while they take more than maxTotal bytes, owns tells them by
+// their path relative to dir. Directories deeper than depth aren't walked.
+// Files being written by rolling files are kept, hidden files, such as spill
+// queues and sequence states, aren't counted.
+func evictOldest(dir string, owns func(rel string) bool, depth int, maxTotal int64) error {
+	evictMutex.Lock()
+	defer evictMutex.Unlock()
+
+	active := activeFiles()
+	var (
+		files []evictFile
+		total int64
+	)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// removed concurrently
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if rel != "." && strings.Count(rel, string(filepath.Separator)) >= depth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || strings.HasPrefix(info.Name(), ".") || !owns(rel) {
+			return nil
+		}
+		total += info.Size()
+		if !active[path] {
+			files = append(files, evictFile{path: path, info: info})
+		}
+		return nil
+	})
+	if err != nil || total <= maxTotal {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].info.ModTime().Before(files[j].info.ModTime())
+	})
+	var errs error
+	for _, f := range files {
+		if total <= maxTotal {
+			break
+		}
+		if err := os.Remove(f.path); err != nil && !os.IsNotExist(err) {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		total -= f.info.Size()
+	}
+	return errs
+}
+
+// activeFiles returns the paths of files open for writing by rolling files.
+func activeFiles() map[string]bool {
+	rollingFiles.Lock()
+	defer rollingFiles.Unlock()
+
+	active := make(map[string]bool)
+	for r := range rollingFiles.m {
+		for _, cp := range r.Checkpoints() {
+			if !cp.Closed {
+				active[cp.Path] = true
+			}
+		}
+	}
+	return active
+}
+
+// ownsFile reports whether the file at rel, relative to the directory of the
+// base path, is written by r: once stripped of its size index, compression and
+// manifest extensions, it matches one of the file patterns of r.
+func (r *RollingFile) ownsFile(rel string) bool {
+	r.rollMutex.RLock()
+	ext := r.fileExt
+	r.rollMutex.RUnlock()
+
+	rel = strings.TrimSuffix(rel, manifestExt)
+	for _, c := range []Compression{GzipCompression, ZstdCompression} {
+		rel = strings.TrimSuffix(rel, c.Ext())
+	}
+	rel = unsizedFilePath(rel, ext)
+	for _, pattern := range r.filePatterns() {
+		if matched, _ := filepath.Match(pattern, rel); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// filePatterns returns the patterns of the unsized paths of the files r
+// writes, relative to the directory of the base path. Files named by a rolling
+// func are only known by their checkpoints.
+func (r *RollingFile) filePatterns() []string {
+	r.rollMutex.RLock()
+	roll, rollFunc, ext := r.rolling, r.rollFunc, r.fileExt
+	template, flat := r.filenameTemplate, r.flatLayout
+	r.rollMutex.RUnlock()
+	dir, name := filepath.Split(r.basePath)
+
+	var patterns []string
+	for _, cp := range r.Checkpoints() {
+		if rel, err := filepath.Rel(dir, cp.Path); err == nil {
+			patterns = append(patterns, unsizedFilePath(rel, ext))
+		}
+	}
+	if rollFunc != nil {
+		return patterns
+	}
+
+	const (
+		d2 = "[0-9][0-9]"
+		d4 = d2 + d2
+	)
+	var pattern string
+	switch {
+	case roll == "":
+		pattern = name + "." + ext
+	case template != "":
+		// the pid changes across restarts
+		pattern = expandFilename(strings.ReplaceAll(template, "{pid}", "*"), name, "*", ext)
+	case flat:
+		pattern = name + "-*." + ext
+	default:
+		nested := map[RollingFormat]string{
+			MonthlyRolling:  d4,
+			DailyRolling:    d4 + d2,
+			HourlyRolling:   d4 + d2 + "/" + d2,
+			MinutelyRolling: d4 + d2 + "/" + d2 + "/" + d2,
+			SecondlyRolling: d4 + d2 + "/" + d2 + "/" + d2 + "/" + d2,
+		}[roll]
+		if nested == "" {
+			return patterns
+		}
+		pattern = nested + "/" + name + "_" + d2 + "." + ext
+	}
+	return append(patterns, filepath.FromSlash(pattern))
+}
+
+// fileDepth returns how many directories below the directory of the base path
+// the files of r are at most.
+func (r *RollingFile) fileDepth() int {
+	depth := 0
+	for _, pattern := range r.filePatterns() {
+		if n := strings.Count(pattern, string(filepath.Separator)); n > depth {
+			depth = n
+		}
+	}
+	return depth
+}
+
+// unsizedFilePath removes the size index inserted by sizedFilePath.
+func unsizedFilePath(path, ext string) string {
+	if !strings.HasSuffix(path, "."+ext) {
+		return path
+	}
+	base := strings.TrimSuffix(path, "."+ext)
+	i := strings.LastIndexByte(base, '.')
+	if i < 0 || i == len(base)-1 || strings.ContainsRune(base[i:], filepath.Separator) {
+		return path
+	}
+	for _, c := range base[i+1:] {
+		if c < '0' || c > '9' {
+			return path
+		}
+	}
+	return base[:i] + "." + ext
+}
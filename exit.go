@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"os"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// fatalHook flushes the outputs after a fatal entry, buffered entries of
+// rolling files would be lost otherwise, runs the hook of the application and
+// then exits.
+type fatalHook struct {
+	state *outputState
+	hook  zapcore.CheckWriteHook
+	exit  func(code int)
+}
+
+func (h fatalHook) OnWrite(ce *zapcore.CheckedEntry, fields []zapcore.Field) {
+	h.state.load().core.Sync()
+	if h.hook != nil {
+		h.hook.OnWrite(ce, fields)
+	}
+	exit := h.exit
+	if exit == nil {
+		exit = os.Exit
+	}
+	exit(1)
+}
+
+// exitDropped runs the fatal hook of a fatal entry a middleware dropped, so
+// Fatal exits whether the entry is written or not.
+func (l *logger) exitDropped(opt *Options, ent zapcore.Entry, fields []zapcore.Field) {
+	hook := fatalHook{state: l.state, hook: opt.fatalHook, exit: opt.exitFunc}
+	(*zapcore.CheckedEntry)(nil).After(ent, hook).Write(fields...)
+}
@@ -0,0 +1,19 @@
+package logger
+
+import (
+	"os"
+	"syscall"
+)
+
+// fallocFlKeepSize reserves blocks without changing the file size, so appends
+// still land at the end of the written data.
+const fallocFlKeepSize = 0x1
+
+func preallocate(f *os.File, size int64) error {
+	err := syscall.Fallocate(int(f.Fd()), fallocFlKeepSize, 0, size)
+	if err == syscall.EOPNOTSUPP || err == syscall.ENOSYS {
+		// not supported by the filesystem
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package logger
+
+import (
+	"os"
+)
+
+// preallocation is only supported on linux.
+func preallocate(f *os.File, size int64) error {
+	return nil
+}
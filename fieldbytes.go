@@ -0,0 +1,106 @@
+package logger
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldBytesStat is the output volume of a field key.
+type FieldBytesStat struct {
+	Key string
+	// Bytes is the estimated bytes of the key in the json output, scaled by the sample rate.
+	Bytes uint64
+	// Share is the fraction of Bytes in the bytes of all keys.
+	Share float64
+}
+
+// FieldBytes samples entries and accounts the bytes each field key contributes
+// to the output, to find which fields to trim. It's registered with
+// Use(a.Middleware()) and only sees the fields of entries, not default fields.
+type FieldBytes struct {
+	rate  uint64
+	count uint64
+
+	mutex sync.Mutex
+	bytes map[string]uint64
+	enc   zapcore.Encoder
+}
+
+// NewFieldBytes create an analyzer measuring one of every rate entries.
+func NewFieldBytes(rate int) *FieldBytes {
+	if rate < 1 {
+		rate = 1
+	}
+	return &FieldBytes{
+		rate:  uint64(rate),
+		bytes: make(map[string]uint64),
+		enc:   zapcore.NewJSONEncoder(zapcore.EncoderConfig{}),
+	}
+}
+
+// Middleware returns the middleware sampling entries.
+func (a *FieldBytes) Middleware() Middleware {
+	return func(next CoreWriter) CoreWriter {
+		return func(ent zapcore.Entry, fields []zapcore.Field) error {
+			if (atomic.AddUint64(&a.count, 1)-1)%a.rate == 0 {
+				a.measure(fields)
+			}
+			return next(ent, fields)
+		}
+	}
+}
+
+func (a *FieldBytes) measure(fields []zapcore.Field) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for _, f := range fields {
+		if f.Type == zapcore.SkipType {
+			continue
+		}
+		buf, err := a.enc.EncodeEntry(zapcore.Entry{}, []zapcore.Field{f})
+		if err != nil {
+			continue
+		}
+		// `{"key":value}\n` is written as `,"key":value` in an entry.
+		if n := buf.Len() - 2; n > 0 {
+			a.bytes[f.Key] += uint64(n) * a.rate
+		}
+		buf.Free()
+	}
+}
+
+// Top returns the n keys contributing most bytes, all keys if n <= 0.
+func (a *FieldBytes) Top(n int) []FieldBytesStat {
+	a.mutex.Lock()
+	stats := make([]FieldBytesStat, 0, len(a.bytes))
+	var total uint64
+	for k, b := range a.bytes {
+		stats = append(stats, FieldBytesStat{Key: k, Bytes: b})
+		total += b
+	}
+	a.mutex.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Bytes != stats[j].Bytes {
+			return stats[i].Bytes > stats[j].Bytes
+		}
+		return stats[i].Key < stats[j].Key
+	})
+	if n > 0 && n < len(stats) {
+		stats = stats[:n]
+	}
+	for i := range stats {
+		stats[i].Share = float64(stats[i].Bytes) / float64(total)
+	}
+	return stats
+}
+
+// Reset clears the accounted bytes.
+func (a *FieldBytes) Reset() {
+	a.mutex.Lock()
+	a.bytes = make(map[string]uint64)
+	a.mutex.Unlock()
+}
@@ -0,0 +1,26 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFieldBytes(t *testing.T) {
+	a := NewFieldBytes(2)
+	log, logs := newObservedLogger(Use(a.Middleware()))
+	for i := 0; i < 4; i++ {
+		log.Infow(msg, "body", strings.Repeat("x", 100), "id", 1)
+	}
+	assert.Len(t, logs.All(), 4)
+
+	top := a.Top(1)
+	assert.Len(t, top, 1)
+	// `,"body":"x..."` sampled twice and scaled by 2.
+	assert.Equal(t, FieldBytesStat{Key: "body", Bytes: 4 * 110, Share: 440.0 / (440 + 4*7)}, top[0])
+	assert.Len(t, a.Top(0), 2)
+
+	a.Reset()
+	assert.Empty(t, a.Top(0))
+}
@@ -0,0 +1,17 @@
+//go:build windows
+// +build windows
+
+package logger
+
+import (
+	"os"
+)
+
+// advisory locks aren't supported, appends rely on O_APPEND only.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -3,9 +3,11 @@ module github.com/go-volo/logger
 go 1.17
 
 require (
+	github.com/klauspost/compress v1.15.15
 	github.com/stretchr/testify v1.8.4
 	go.uber.org/multierr v1.8.0
 	go.uber.org/zap v1.24.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -14,5 +16,4 @@ require (
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"context"
+	"strings"
+)
+
+// DefaultGRPCKeys are the metadata keys extracted by GRPCFields when none
+// are given.
+var DefaultGRPCKeys = []string{"x-request-id", ":authority"}
+
+// GRPCFields returns the key-value pairs of the metadata keys of md, such as
+// the metadata.MD of metadata.FromIncomingContext, and of the method of the
+// call if not empty. Keys are lowercased like gRPC does and the leading colon
+// of pseudo headers is dropped, e.g. :authority is logged as authority.
+// Missing keys are skipped, multiple values are joined by commas.
+func GRPCFields(method string, md map[string][]string, keys ...string) []interface{} {
+	if len(keys) == 0 {
+		keys = DefaultGRPCKeys
+	}
+	kv := make([]interface{}, 0, 2*len(keys)+2)
+	if method != "" {
+		kv = append(kv, "method", method)
+	}
+	for _, key := range keys {
+		key = strings.ToLower(key)
+		values := md[key]
+		if len(values) == 0 {
+			continue
+		}
+		kv = append(kv, strings.TrimPrefix(key, ":"), strings.Join(values, ","))
+	}
+	return kv
+}
+
+// AppendGRPCFields returns a copy of ctx carrying the fields of GRPCFields,
+// added to entries by loggers given the context, e.g. in a unary server
+// interceptor:
+//
+//	md, _ := metadata.FromIncomingContext(ctx)
+//	ctx = logger.AppendGRPCFields(ctx, info.FullMethod, md)
+//	return handler(ctx, req)
+func AppendGRPCFields(ctx context.Context, method string, md map[string][]string, keys ...string) context.Context {
+	return AppendCtxFields(ctx, GRPCFields(method, md, keys...)...)
+}
@@ -0,0 +1,27 @@
+package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGRPCFields(t *testing.T) {
+	md := map[string][]string{
+		"x-request-id": {"r1"},
+		":authority":   {"api.example.com"},
+		"x-tenant":     {"a", "b"},
+	}
+	assert.Equal(t, []interface{}{"method", "/pkg.Service/Get", "x-request-id", "r1", "authority", "api.example.com"},
+		GRPCFields("/pkg.Service/Get", md))
+	assert.Equal(t, []interface{}{"x-tenant", "a,b"}, GRPCFields("", md, "X-Tenant", "x-missing"))
+
+	log, logs := newObservedLogger()
+	log.InfoCtx(AppendGRPCFields(context.Background(), "/pkg.Service/Get", md), msg)
+	assert.Equal(t, map[string]interface{}{
+		"method":       "/pkg.Service/Get",
+		"x-request-id": "r1",
+		"authority":    "api.example.com",
+	}, logs.TakeAll()[0].ContextMap())
+}
@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// fileHeaderKey marks the header line of files, so it's not taken for an entry.
+const fileHeaderKey = "log_header"
+
+// writeHeader writes the header line to the new active file created at now.
+func (r *RollingFile) writeHeader(fields map[string]interface{}, now time.Time) error {
+	header := make(map[string]interface{}, len(fields)+4)
+	for k, v := range fields {
+		header[k] = v
+	}
+	header[fileHeaderKey] = true
+	header["hostname"], _ = os.Hostname()
+	header["pid"] = os.Getpid()
+	header["created"] = now.Format(time.RFC3339Nano)
+
+	b, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	_, err = r.writeFile(append(b, '\n'))
+	return err
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 )
 
-// WithContext returns a shallow copy of l with its context changed
-// to ctx. The provided ctx must be non-nil.
+// WithContext returns a shallow copy of DefaultLogger with its context
+// changed to ctx, a nil ctx is context.Background().
 func WithContext(ctx context.Context) Logger {
 	return DefaultLogger.WithContext(ctx)
 }
@@ -15,29 +15,37 @@ func Fields(fields map[string]interface{}) Logger {
 	return DefaultLogger.WithFields(fields)
 }
 
-// SetLevel set logger level
-func SetLevel(lv Level) {
-	DefaultLogger.SetLevel(lv)
+// WithoutFields is a helper to drop the fields of DefaultLogger with the
+// given keys, DefaultLogger is returned as is if it doesn't implement
+// FieldReplacer.
+func WithoutFields(keys ...string) Logger {
+	if r, ok := DefaultLogger.(FieldReplacer); ok {
+		return r.WithoutFields(keys...)
+	}
+	return DefaultLogger
 }
 
-// Debug uses fmt.Sprint to construct and log a message.
-func Debug(args ...interface{}) {
-	DefaultLogger.WithCallDepth(1).Debug(args...)
+// ReplaceFields is a helper to override the fields of DefaultLogger, fields
+// are added by WithFields if it doesn't implement FieldReplacer.
+func ReplaceFields(fields map[string]interface{}) Logger {
+	if r, ok := DefaultLogger.(FieldReplacer); ok {
+		return r.ReplaceFields(fields)
+	}
+	return DefaultLogger.WithFields(fields)
 }
 
-// Debugf uses fmt.Sprintf to log a templated message.
-func Debugf(format string, args ...interface{}) {
-	DefaultLogger.WithCallDepth(1).Debugf(format, args...)
+// Namespace is a helper to nest fields added afterwards under name,
+// DefaultLogger is returned as is if it doesn't implement Namespacer.
+func Namespace(name string) Logger {
+	if n, ok := DefaultLogger.(Namespacer); ok {
+		return n.Namespace(name)
+	}
+	return DefaultLogger
 }
 
-// Debugw logs a message with some additional context. The variadic key-value
-// pairs are treated as they are in With.
-//
-// When debug-level logging is disabled, this is much faster than
-//
-//	s.With(keysAndValues).Debug(msg)
-func Debugw(msg string, keysAndValues ...interface{}) {
-	DefaultLogger.WithCallDepth(1).Debugw(msg, keysAndValues...)
+// SetLevel set logger level
+func SetLevel(lv Level) {
+	DefaultLogger.SetLevel(lv)
 }
 
 // Info uses fmt.Sprint to construct and log a message.
@@ -88,6 +96,39 @@ func Errorw(msg string, keysAndValues ...interface{}) {
 	DefaultLogger.WithCallDepth(1).Errorw(msg, keysAndValues...)
 }
 
+// InfoCtx logs a message with some additional context like Infow, ctx is
+// used as by WithContext.
+func InfoCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	l := DefaultLogger.WithCallDepth(1)
+	if c, ok := l.(ContextLogger); ok {
+		c.InfoCtx(ctx, msg, keysAndValues...)
+		return
+	}
+	l.WithContext(ctx).Infow(msg, keysAndValues...)
+}
+
+// WarnCtx logs a message with some additional context like Warnw, ctx is
+// used as by WithContext.
+func WarnCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	l := DefaultLogger.WithCallDepth(1)
+	if c, ok := l.(ContextLogger); ok {
+		c.WarnCtx(ctx, msg, keysAndValues...)
+		return
+	}
+	l.WithContext(ctx).Warnw(msg, keysAndValues...)
+}
+
+// ErrorCtx logs a message with some additional context like Errorw, ctx is
+// used as by WithContext.
+func ErrorCtx(ctx context.Context, msg string, keysAndValues ...interface{}) {
+	l := DefaultLogger.WithCallDepth(1)
+	if c, ok := l.(ContextLogger); ok {
+		c.ErrorCtx(ctx, msg, keysAndValues...)
+		return
+	}
+	l.WithContext(ctx).Errorw(msg, keysAndValues...)
+}
+
 // Fatal uses fmt.Sprint to construct and log a message, then calls os.Exit.
 // Deprecated: 记录消息后，直接调用 os.Exit(1)，这意味着：
 // 在其他 goroutine defer 语句不会被执行；
@@ -123,3 +164,21 @@ func Fatalw(msg string, keysAndValues ...interface{}) {
 func Sync() error {
 	return DefaultLogger.Sync()
 }
+
+// Flush writes buffered entries of DefaultLogger to its outputs, returning
+// when ctx is done. DefaultLogger is synced if it doesn't implement Flusher.
+func Flush(ctx context.Context) error {
+	if f, ok := DefaultLogger.(Flusher); ok {
+		return f.Flush(ctx)
+	}
+	return DefaultLogger.Sync()
+}
+
+// Close flushes and closes the outputs of DefaultLogger, returning when ctx
+// is done. DefaultLogger is only synced if it doesn't implement Closer.
+func Close(ctx context.Context) error {
+	if c, ok := DefaultLogger.(Closer); ok {
+		return c.Close(ctx)
+	}
+	return DefaultLogger.Sync()
+}
@@ -1 +1,34 @@
 package logger
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// plainLogger implements Logger only, like loggers outside the package.
+type plainLogger struct {
+	Logger
+}
+
+func (l plainLogger) WithCallDepth(callDepth int) Logger {
+	return plainLogger{l.Logger.WithCallDepth(callDepth)}
+}
+
+func TestHelpers_PlainLogger(t *testing.T) {
+	var buf syncBuffer
+	prev := DefaultLogger
+	DefaultLogger = plainLogger{New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf))}
+	defer func() { DefaultLogger = prev }()
+
+	// the context is passed by WithContext instead
+	WarnCtx(AppendCtxFields(context.Background(), "request_id", "r1"), msg)
+	assert.Contains(t, buf.String(), `"request_id":"r1"`)
+	assert.Equal(t, DefaultLogger, Namespace("db"))
+	assert.Equal(t, DefaultLogger, WithoutFields("app"))
+	ReplaceFields(map[string]interface{}{"app": "api"}).Info(msg)
+	assert.Contains(t, buf.String(), `"app":"api"`)
+	assert.NoError(t, Flush(context.Background()))
+	assert.NoError(t, Close(context.Background()))
+}
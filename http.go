@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// RequestIDHeader is the header RequestIDHandler reads the request ID from.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the length of the longest request ID accepted from
+// clients, room for UUIDs and most trace IDs.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying id, added as the request_id
+// field to entries by loggers given the context.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return AppendCtxFields(context.WithValue(ctx, requestIDKey{}, id), "request_id", id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, empty if none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestIDHandler returns a handler calling next with the request ID of the
+// X-Request-ID header in the context of the request, or one returned by
+// generator if the header is missing or invalid, UUIDv7 if generator is nil.
+// Valid IDs have up to 128 letters, digits, '-', '_', '.' or ':', so clients
+// can't forge entries or bloat them. The ID is sent back in the X-Request-ID
+// header of the response, e.g.
+//
+//	http.ListenAndServe(addr, logger.RequestIDHandler(mux, nil))
+//
+// and handlers log with logger.InfoCtx(r.Context(), msg).
+func RequestIDHandler(next http.Handler, generator IDGenerator) http.Handler {
+	if generator == nil {
+		generator = UUIDv7
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = generator()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID reports whether id is accepted as a request ID from clients.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-_.:", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
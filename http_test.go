@@ -0,0 +1,40 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDHandler(t *testing.T) {
+	log, logs := newObservedLogger()
+	h := RequestIDHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.InfoCtx(r.Context(), msg)
+	}), func() string { return "generated" })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "r1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, "r1", rec.Header().Get(RequestIDHeader))
+	assert.Equal(t, map[string]interface{}{"request_id": "r1"}, logs.TakeAll()[0].ContextMap())
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, "generated", rec.Header().Get(RequestIDHeader))
+	assert.Equal(t, map[string]interface{}{"request_id": "generated"}, logs.TakeAll()[0].ContextMap())
+
+	// invalid IDs are replaced
+	for _, id := range []string{"r1\n{\"level\":\"error\"}", "r 1", strings.Repeat("r", 129)} {
+		req = httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, id)
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, "generated", rec.Header().Get(RequestIDHeader))
+		assert.Equal(t, map[string]interface{}{"request_id": "generated"}, logs.TakeAll()[0].ContextMap())
+	}
+	assert.True(t, validRequestID("0190a2c4-7c1e-7b4a-9f2e-3d5c6b7a8e9f"))
+}
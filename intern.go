@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// interner deduplicates frequently repeated string values, such as service
+// names, routes or tenant IDs, so that long-lived fields share one copy.
+// Once size distinct values are interned, new values are returned as is.
+type interner struct {
+	mu   sync.RWMutex
+	size int
+	m    map[string]string
+}
+
+func newInterner(size int) *interner {
+	return &interner{
+		size: size,
+		m:    make(map[string]string, size),
+	}
+}
+
+func (i *interner) intern(s string) string {
+	i.mu.RLock()
+	v, ok := i.m[s]
+	full := len(i.m) >= i.size
+	i.mu.RUnlock()
+	if ok {
+		return v
+	}
+	if full {
+		// the table never shrinks, skip the write lock
+		return s
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if v, ok = i.m[s]; ok {
+		return v
+	}
+	if len(i.m) >= i.size {
+		return s
+	}
+	i.m[s] = s
+	return s
+}
+
+// internFields interns the values of string fields in place.
+func (i *interner) internFields(fields []zap.Field) []zap.Field {
+	if i == nil {
+		return fields
+	}
+	for idx := range fields {
+		if fields[idx].Type == zapcore.StringType {
+			fields[idx].String = i.intern(fields[idx].String)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,33 @@
+package logger
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestInterner(t *testing.T) {
+	i := newInterner(1)
+	a := string([]byte("svc"))
+	b := string([]byte("svc"))
+	assert.Equal(t, "svc", i.intern(a))
+	assert.Equal(t, "svc", i.intern(b))
+	assert.Equal(t, "other", i.intern("other"))
+	assert.Len(t, i.m, 1)
+
+	fields := i.internFields([]zap.Field{zap.String("service", b), zap.Int("n", 1)})
+	assert.Equal(t, "svc", fields[0].String)
+
+	var nilInterner *interner
+	assert.Len(t, nilInterner.internFields(fields), 2)
+}
+
+func TestStringInterning(t *testing.T) {
+	log, _ := newObservedLogger(WithStringInterning(1))
+	log.Infow(msg, "request_id", "r1")
+	assert.Empty(t, log.outputs().opt.interner.m)
+
+	log.WithFields(map[string]interface{}{"service": "svc"})
+	assert.Equal(t, map[string]string{"svc": "svc"}, log.outputs().opt.interner.m)
+}
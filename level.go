@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"fmt"
 	"strings"
 
 	"go.uber.org/zap"
@@ -23,6 +24,9 @@ const (
 	FatalLevel
 )
 
+// levels is all levels, from lowest to highest.
+var levels = []Level{DebugLevel, InfoLevel, WarnLevel, ErrorLevel, FatalLevel}
+
 func (l Level) String() string {
 	switch l {
 	case DebugLevel:
@@ -39,21 +43,33 @@ func (l Level) String() string {
 	return ""
 }
 
-// ParseLevel parses a level string into a logger Level value.
-func ParseLevel(s string) Level {
+// ParseLevel parses a level string, case insensitively, into a logger Level
+// value. Unknown strings return InfoLevel and an error.
+func ParseLevel(s string) (Level, error) {
 	switch strings.ToUpper(s) {
 	case "DEBUG":
-		return DebugLevel
+		return DebugLevel, nil
 	case "INFO":
-		return InfoLevel
-	case "WARN":
-		return WarnLevel
+		return InfoLevel, nil
+	case "WARN", "WARNING":
+		return WarnLevel, nil
 	case "ERROR":
-		return ErrorLevel
+		return ErrorLevel, nil
 	case "FATAL":
-		return FatalLevel
+		return FatalLevel, nil
 	}
-	return InfoLevel
+	return InfoLevel, fmt.Errorf("unknown level %q", s)
+}
+
+// UnmarshalText parses a level string, so levels can be read from config
+// files and flags.
+func (l *Level) UnmarshalText(text []byte) error {
+	lv, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = lv
+	return nil
 }
 
 func (l Level) unmarshalZapLevel() zapcore.Level {
@@ -72,3 +88,30 @@ func (l Level) unmarshalZapLevel() zapcore.Level {
 		return zap.InfoLevel
 	}
 }
+
+func levelFromZap(l zapcore.Level) Level {
+	switch l {
+	case zap.DebugLevel:
+		return DebugLevel
+	case zap.InfoLevel:
+		return InfoLevel
+	case zap.WarnLevel:
+		return WarnLevel
+	case zap.ErrorLevel:
+		return ErrorLevel
+	case zap.DPanicLevel, zap.PanicLevel, zap.FatalLevel:
+		return FatalLevel
+	default:
+		return InfoLevel
+	}
+}
+
+// valid reports whether l is one of the levels.
+func (l Level) valid() bool {
+	for _, lv := range levels {
+		if l == lv {
+			return true
+		}
+	}
+	return false
+}
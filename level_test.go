@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLevel(t *testing.T) {
+	lv, err := ParseLevel("warn")
+	assert.NoError(t, err)
+	assert.Equal(t, Level(WarnLevel), lv)
+
+	lv, err = ParseLevel("ERROR")
+	assert.NoError(t, err)
+	assert.Equal(t, Level(ErrorLevel), lv)
+
+	lv, err = ParseLevel("verbose")
+	assert.Error(t, err)
+	assert.Equal(t, Level(InfoLevel), lv)
+
+	assert.NoError(t, lv.UnmarshalText([]byte("debug")))
+	assert.Equal(t, Level(DebugLevel), lv)
+}
+
+func TestWithLevelString(t *testing.T) {
+	opt := newOptions(WithLevelString("warn"))
+	assert.Equal(t, Level(WarnLevel), opt.level)
+	assert.NoError(t, opt.Validate())
+
+	opt = newOptions(WithLevel(ErrorLevel), WithLevelString("verbose"))
+	assert.Equal(t, Level(ErrorLevel), opt.level)
+	var optErr *OptionError
+	assert.True(t, errors.As(opt.Validate(), &optErr))
+	assert.Equal(t, "WithLevelString", optErr.Option)
+}
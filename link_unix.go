@@ -0,0 +1,22 @@
+//go:build !windows
+// +build !windows
+
+package logger
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// linkLatest points sym at real with a relative symlink, so the log directory
+// can be moved or mounted elsewhere.
+func linkLatest(real, sym string) error {
+	if rel, err := filepath.Rel(filepath.Dir(sym), real); err == nil {
+		real = rel
+	}
+	return os.Symlink(real, sym)
+}
+
+func platformFileMode(mode os.FileMode) os.FileMode {
+	return mode
+}
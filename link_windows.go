@@ -0,0 +1,30 @@
+//go:build windows
+// +build windows
+
+package logger
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// linkLatest points sym at real. Creating symlinks needs the developer mode or
+// an elevated process on Windows, a hard link sharing the data of the active
+// file is made when it fails, junctions only apply to directories.
+func linkLatest(real, sym string) error {
+	target := real
+	if rel, err := filepath.Rel(filepath.Dir(sym), real); err == nil {
+		target = rel
+	}
+	if err := os.Symlink(target, sym); err == nil {
+		return nil
+	}
+	return os.Link(real, sym)
+}
+
+// platformFileMode keeps files writable by the owner. Windows only honors the
+// write bit and creates files without it read-only, which then can't be
+// reopened, compressed or evicted.
+func platformFileMode(mode os.FileMode) os.FileMode {
+	return mode | 0200
+}
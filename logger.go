@@ -15,7 +15,8 @@ type Logger interface {
 	Options() Options
 	// SetLevel set logger level
 	SetLevel(lv Level)
-	// WithContext with context
+	// WithContext with context, nil is context.Background(). The returned
+	// logger shares the level and outputs of the logger.
 	WithContext(ctx context.Context) Logger
 	// WithFields set fields to always be logged
 	WithFields(fields map[string]interface{}) Logger
@@ -64,3 +65,58 @@ type Logger interface {
 	// Sync logger sync
 	Sync() error
 }
+
+// Flusher is implemented by loggers whose outputs can be flushed within a
+// deadline, such as the loggers created by New.
+type Flusher interface {
+	// Flush writes buffered entries to the outputs, returning when ctx is done
+	Flush(ctx context.Context) error
+}
+
+// ContextLogger is implemented by loggers taking the context of each entry,
+// such as the loggers created by New.
+type ContextLogger interface {
+	// DebugCtx logs a message with some additional context, ctx is used as
+	// by WithContext without cloning the logger.
+	DebugCtx(ctx context.Context, msg string, keysAndValues ...interface{})
+	// InfoCtx logs a message with some additional context, ctx is used as by
+	// WithContext without cloning the logger.
+	InfoCtx(ctx context.Context, msg string, keysAndValues ...interface{})
+	// WarnCtx logs a message with some additional context, ctx is used as by
+	// WithContext without cloning the logger.
+	WarnCtx(ctx context.Context, msg string, keysAndValues ...interface{})
+	// ErrorCtx logs a message with some additional context, ctx is used as by
+	// WithContext without cloning the logger.
+	ErrorCtx(ctx context.Context, msg string, keysAndValues ...interface{})
+}
+
+// FieldReplacer is implemented by loggers dropping inherited fields, such as
+// the loggers created by New.
+type FieldReplacer interface {
+	// WithoutFields drop inherited fields with the given keys
+	WithoutFields(keys ...string) Logger
+	// ReplaceFields override inherited fields with the given ones
+	ReplaceFields(fields map[string]interface{}) Logger
+}
+
+// Namespacer is implemented by loggers nesting fields under a name, such as
+// the loggers created by New.
+type Namespacer interface {
+	// Namespace nest fields added afterwards under name, chained calls nest
+	// further
+	Namespace(name string) Logger
+}
+
+// Closer is implemented by loggers whose outputs can be closed, such as the
+// loggers created by New.
+type Closer interface {
+	// Close flushes and closes the outputs, returning when ctx is done
+	Close(ctx context.Context) error
+}
+
+// Querier is implemented by loggers whose log files can be queried, such as
+// the loggers created by New.
+type Querier interface {
+	// Query returns the entries of the log files matching spec
+	Query(spec QuerySpec) ([]LogEntry, error)
+}
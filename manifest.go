@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// manifestExt is the extension appended to rotated files for their manifests.
+const manifestExt = ".manifest.json"
+
+// Manifest describes a rotated file, so shippers can verify its integrity.
+type Manifest struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// writeManifest writes the manifest of the file at path to path+manifestExt
+// with the given mode.
+func writeManifest(path string, mode os.FileMode) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(Manifest{
+		Name:   filepath.Base(path),
+		Size:   size,
+		SHA256: hex.EncodeToString(h.Sum(nil)),
+	})
+	if err != nil {
+		return err
+	}
+
+	// written atomically, a manifest present is complete
+	tmp := path + manifestExt + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), platformFileMode(mode)); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path+manifestExt)
+}
+
+// VerifyManifest checks the file at path against its manifest, it returns an
+// error if the file was truncated or modified.
+func VerifyManifest(path string) error {
+	b, err := os.ReadFile(path + manifestExt)
+	if err != nil {
+		return err
+	}
+	var want Manifest
+	if err := json.Unmarshal(b, &want); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if size != want.Size || hex.EncodeToString(h.Sum(nil)) != want.SHA256 {
+		return ErrManifestMismatch
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package logger
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// CoreWriter writes an entry with its fields to the outputs of the logger.
+type CoreWriter func(ent zapcore.Entry, fields []zapcore.Field) error
+
+// Middleware wraps a CoreWriter to enrich, redact, route or measure entries,
+// it drops an entry by not calling next. Fatal entries dropped still exit.
+type Middleware func(next CoreWriter) CoreWriter
+
+// Use register middlewares applied to every entry once, before it's written
+// to the outputs. The first registered middleware is the outermost one.
+func Use(middlewares ...Middleware) Option {
+	return func(o *Options) {
+		o.middlewares = append(o.middlewares, middlewares...)
+	}
+}
+
+// chainMiddlewares composes middlewares around last.
+func chainMiddlewares(middlewares []Middleware, last CoreWriter) CoreWriter {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		last = middlewares[i](last)
+	}
+	return last
+}
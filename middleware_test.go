@@ -0,0 +1,123 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestUse(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next CoreWriter) CoreWriter {
+			return func(ent zapcore.Entry, fields []zapcore.Field) error {
+				order = append(order, name)
+				return next(ent, append(fields, zap.String(name, "ok")))
+			}
+		}
+	}
+	drop := func(next CoreWriter) CoreWriter {
+		return func(ent zapcore.Entry, fields []zapcore.Field) error {
+			if ent.Level == zap.WarnLevel {
+				return nil
+			}
+			return next(ent, fields)
+		}
+	}
+
+	log, logs := newObservedLogger(Use(trace("a"), trace("b"), drop))
+	log.Infow(msg)
+	log.Warnw(msg)
+
+	assert.Equal(t, []string{"a", "b", "a", "b"}, order)
+	entries := logs.AllUntimed()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, map[string]interface{}{"a": "ok", "b": "ok"}, entries[0].ContextMap())
+}
+
+func TestUse_DropFatal(t *testing.T) {
+	var calls []string
+	drop := func(next CoreWriter) CoreWriter {
+		return func(ent zapcore.Entry, fields []zapcore.Field) error {
+			return nil
+		}
+	}
+	log := New(
+		WithConsole(false),
+		WithDisableDisk(true),
+		WithDualFormat(io.Discard),
+		Use(drop),
+		WithFatalHook(fatalHookFunc(func(ce *zapcore.CheckedEntry, fields []zapcore.Field) {
+			calls = append(calls, "hook "+ce.Message)
+		})),
+		WithExitFunc(func(code int) { calls = append(calls, "exit") }),
+	)
+
+	log.Fatal(msg)
+	assert.Equal(t, []string{"hook " + msg, "exit"}, calls)
+}
+
+// fieldsEncoder records the fields of the entries it encodes.
+type fieldsEncoder struct {
+	zapcore.Encoder
+	mu     *sync.Mutex
+	fields *[]zapcore.Field
+}
+
+func (e fieldsEncoder) Clone() zapcore.Encoder {
+	return fieldsEncoder{Encoder: e.Encoder.Clone(), mu: e.mu, fields: e.fields}
+}
+
+func (e fieldsEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	e.mu.Lock()
+	*e.fields = append(*e.fields, fields...)
+	e.mu.Unlock()
+	return e.Encoder.EncodeEntry(ent, fields)
+}
+
+func TestUse_NoContextField(t *testing.T) {
+	var (
+		mu                   sync.Mutex
+		seen, encoded, piped []zapcore.Field
+	)
+	record := func(next CoreWriter) CoreWriter {
+		return func(ent zapcore.Entry, fields []zapcore.Field) error {
+			seen = append(seen, fields...)
+			return next(ent, fields)
+		}
+	}
+	pipe := func(ent *zapcore.Entry, fields []zapcore.Field) ([]zapcore.Field, bool) {
+		piped = append(piped, fields...)
+		return fields, true
+	}
+	log := New(
+		WithBasePath(t.TempDir()),
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithFilename("app"),
+		WithEncoderFactory(func(cfg zapcore.EncoderConfig) zapcore.Encoder {
+			return fieldsEncoder{Encoder: zapcore.NewJSONEncoder(cfg), mu: &mu, fields: &encoded}
+		}),
+		WithPipeline(Pipeline{Output: zapcore.AddSync(io.Discard), Transforms: []Transform{pipe}}),
+		Use(record),
+	).(*logger)
+	defer log.Close(context.Background())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	log.InfoCtx(ctx, msg, "key", "value")
+	assert.NoError(t, log.Sync())
+
+	for name, fields := range map[string][]zapcore.Field{"middleware": seen, "core": encoded, "pipeline": piped} {
+		assert.NotEmpty(t, fields, name)
+		for _, f := range fields {
+			assert.NotEqual(t, zapcore.SkipType, f.Type, name)
+		}
+	}
+}
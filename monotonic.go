@@ -0,0 +1,62 @@
+package logger
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// monotonicCore guarantees non-decreasing entry timestamps per output, an
+// entry whose time is not after the previous one is moved 1ns past it. Cores
+// writing to the same output share its clock.
+type monotonicCore struct {
+	zapcore.Core
+	clock *monotonicClock
+}
+
+type monotonicClock struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// newMonotonicClock returns the clock of an output, nil if timestamps aren't
+// made monotonic.
+func newMonotonicClock(opt Options) *monotonicClock {
+	if !opt.monotonicTime {
+		return nil
+	}
+	return &monotonicClock{}
+}
+
+// newMonotonicCore stamps the entries of core by clock, core is returned as
+// is if clock is nil.
+func newMonotonicCore(core zapcore.Core, clock *monotonicClock) zapcore.Core {
+	if clock == nil {
+		return core
+	}
+	return &monotonicCore{Core: core, clock: clock}
+}
+
+func (c *monotonicCore) With(fields []zapcore.Field) zapcore.Core {
+	return &monotonicCore{Core: c.Core.With(fields), clock: c.clock}
+}
+
+func (c *monotonicCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *monotonicCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	// hold the lock while writing, so entries reach the output in timestamp order
+	c.clock.mu.Lock()
+	defer c.clock.mu.Unlock()
+
+	if !ent.Time.After(c.clock.last) {
+		ent.Time = c.clock.last.Add(time.Nanosecond)
+	}
+	c.clock.last = ent.Time
+	return c.Core.Write(ent, fields)
+}
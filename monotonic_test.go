@@ -0,0 +1,56 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestMonotonicCore(t *testing.T) {
+	obs, logs := observer.New(zap.DebugLevel)
+	core := newMonotonicCore(obs, newMonotonicClock(newOptions(WithMonotonicTime(true))))
+
+	now := time.Now()
+	for _, ts := range []time.Time{now, now.Add(-time.Second), now} {
+		ent := zapcore.Entry{Level: zap.InfoLevel, Time: ts, Message: msg}
+		core.Check(ent, nil).Write()
+	}
+
+	entries := logs.All()
+	assert.Len(t, entries, 3)
+	assert.Equal(t, now.UnixNano(), entries[0].Time.UnixNano())
+	assert.Equal(t, now.UnixNano()+1, entries[1].Time.UnixNano())
+	assert.Equal(t, now.UnixNano()+2, entries[2].Time.UnixNano())
+}
+
+func TestMonotonicClockPerOutput(t *testing.T) {
+	log := New(WithConsole(true), WithDisableDisk(true), WithMonotonicTime(true)).(*logger)
+	clock := func(core zapcore.Core) *monotonicClock {
+		return core.(*levelCore).Core.(*monotonicCore).clock
+	}
+
+	// debug, info and warn go to stdout, error and fatal to stderr
+	cores := log.buildConsole()
+	assert.Same(t, clock(cores[0]), clock(cores[1]))
+	assert.Same(t, clock(cores[0]), clock(cores[2]))
+	assert.Same(t, clock(cores[3]), clock(cores[4]))
+	assert.NotSame(t, clock(cores[0]), clock(cores[3]))
+
+	log = New(
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithMonotonicTime(true),
+		WithLevelFilenames(map[Level]string{WarnLevel: infoFilename}),
+		WithRotator(func(path string) (RotatingWriter, error) {
+			return &memRotator{path: path}, nil
+		}),
+	).(*logger)
+	cores, err := log.buildFiles()
+	assert.NoError(t, err)
+	assert.Same(t, clock(cores[1]), clock(cores[2]), "info and warn share a file")
+	assert.NotSame(t, clock(cores[0]), clock(cores[1]))
+}
@@ -1,8 +1,15 @@
 package logger
 
 import (
+	"context"
 	"errors"
+	"io"
+	"os"
+	"path"
+	"sync"
+	"time"
 
+	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
@@ -34,6 +41,8 @@ type Options struct {
 	console bool
 	// disableDisk disable rolling file
 	disableDisk bool
+	// callerMode is how callers are reported.
+	callerMode CallerMode
 	// callerSkip is the number of stack frames to ascend when logging caller info.
 	callerSkip int
 	// namespace is the namespace of logger.
@@ -44,6 +53,162 @@ type Options struct {
 	encoder Encoder
 	// encoderConfig is the encoder config of logger.
 	encoderConfig zapcore.EncoderConfig
+	// compression is the compression of rolled files.
+	compression Compression
+	// compressionLevel is the compression level of rolled files.
+	compressionLevel int
+	// compressionDelay is the grace period before rolled files are compressed.
+	compressionDelay time.Duration
+	// fileExt is the extension of log files.
+	fileExt string
+	// fileMode is the permission bits of log files.
+	fileMode os.FileMode
+	// dirMode is the permission bits of log directories.
+	dirMode os.FileMode
+	// callerTrimPrefixes is the prefixes trimmed from caller paths.
+	callerTrimPrefixes []string
+	// callerStripPatterns is the patterns of caller paths to be dropped.
+	callerStripPatterns []string
+	// callerSkipPackages is the import paths of wrapper packages skipped when resolving caller.
+	callerSkipPackages []string
+	// flatLayout keeps rolled files in a single directory.
+	flatLayout bool
+	// filenameTemplate is the template of rolled file names.
+	filenameTemplate string
+	// preallocSize is the disk space reserved for new rolled files.
+	preallocSize int64
+	// fileHeader is the fields of the header line of new rolled files, nil disables it.
+	fileHeader map[string]interface{}
+	// manifest writes manifests of rotated files.
+	manifest bool
+	// maxTotalSize is the bytes all files under basePath may take, 0 means no limit.
+	maxTotalSize int64
+	// writeRateLimit is the bytes per second rolling files write at most, 0 means no limit.
+	writeRateLimit int64
+	// symlink maintains a symlink to the active rolled file.
+	symlink bool
+	// interner interns repeated string field values.
+	interner *interner
+	// bufferPoolSize is the number of buffers of rolling files, 0 means the shared pool.
+	bufferPoolSize int
+	// directWrite writes entries straight to rolling files, bypassing buffers.
+	directWrite bool
+	// directSync fsyncs every direct write.
+	directSync bool
+	// flushThreshold is the bytes buffered before flushing to rolling files.
+	flushThreshold int
+	// overflowPolicy is what rolling files do when the buffer pool is exhausted.
+	overflowPolicy OverflowPolicy
+	// idKey is the field key of entry IDs.
+	idKey string
+	// idGenerator generates entry IDs, nil disables them.
+	idGenerator IDGenerator
+	// monotonicTime guarantees non-decreasing timestamps per output.
+	monotonicTime bool
+	// errorHandler is called when rolling files fail to persist logs.
+	errorHandler func(error)
+	// diskFullFallback writes logs to stderr while the disk is full.
+	diskFullFallback bool
+	// consoleProfile is the time and level rendering of the console encoder.
+	consoleProfile *ConsoleProfile
+	// rollingFunc is the custom rolling layout of rolling files.
+	rollingFunc RollingFunc
+	// maxSize is the size in bytes rolling files roll at, besides time.
+	maxSize int64
+	// pipelines is the additional outputs with their own transforms.
+	pipelines []Pipeline
+	// rollingLocation is the time zone of rolling file names, nil means local time.
+	rollingLocation *time.Location
+	// singleFile is the file all levels are written to, empty means a file per level.
+	singleFile string
+	// levelFilenames is the file names of levels when no filename is set.
+	levelFilenames map[Level]string
+	// levelFiles is the levels having a file when no filename is set, nil means all.
+	levelFiles []Level
+	// flushInterval is the interval rolling files flush buffered data at.
+	flushInterval time.Duration
+	// syncPolicy is when rolling files fsync flushed data.
+	syncPolicy SyncPolicy
+	// syncInterval is the interval of SyncPeriodically.
+	syncInterval time.Duration
+	// watchInterval is the interval rolling files check whether they were deleted or moved.
+	watchInterval time.Duration
+	// fileLock holds an advisory flock on rolling files while writing.
+	fileLock bool
+	// jsonCopy receives a json copy of every entry in dual format mode, nil disables it.
+	jsonCopy zapcore.WriteSyncer
+	// onRotated is called with the path of every rotated file.
+	onRotated func(path string) error
+	// beforeRotate is called right before rolling files close the active file.
+	beforeRotate func(path string, w io.Writer)
+	// afterRotate is called right after rolling files open the new file.
+	afterRotate func(oldPath, newPath string)
+	// routingRules routes entries to named pipelines.
+	routingRules []RoutingRule
+	// shardKey is the field whose values rolling files are sharded by, empty disables sharding.
+	shardKey string
+	// maxOpenShards is the number of shard files kept open.
+	maxOpenShards int
+	// onPressure is called when rolling files enter or leave a degraded state.
+	onPressure func(degraded bool)
+	// rotator creates the writers of log files, nil means RollingFile.
+	rotator Rotator
+	// sequenceKey is the field key of per file sequence numbers, empty disables them.
+	sequenceKey string
+	// runtimeFields attaches hostname, pid, go version and app version to entries.
+	runtimeFields bool
+	// appVersion is the app version of runtime fields, empty means the version from build info.
+	appVersion string
+	// resourceDetectors detects the resource attributes attached to entries.
+	resourceDetectors []ResourceDetector
+	// levelErr is the error parsing the level string.
+	levelErr error
+	// maxMessageSize is the bytes messages are truncated to, 0 keeps them whole.
+	maxMessageSize int
+	// stacktraceLevel is the level entries at or above include a stack trace, 0 disables stack traces.
+	stacktraceLevel Level
+	// clock tells the time of entries and rolling file names, nil means the system clock.
+	clock zapcore.Clock
+	// fatalHook is run after fatal entries were written, before exiting.
+	fatalHook zapcore.CheckWriteHook
+	// exitFunc is called with the exit code after fatal entries, nil means os.Exit.
+	exitFunc func(code int)
+	// errorOutput receives internal errors of zap, such as failed writes, nil means stderr.
+	errorOutput zapcore.WriteSyncer
+	// sampling limits entries with the same level and message, zero tick disables it.
+	sampling sampling
+	// bufferedOutput buffers console and file outputs, zero size disables it.
+	bufferedOutput bufferedOutputOptions
+	// baggageSource returns the baggage members of contexts.
+	baggageSource BaggageSource
+	// baggageKeys is the baggage members added to entries as fields.
+	baggageKeys []string
+	// contextKeys is the context keys whose values are added to entries.
+	contextKeys []interface{}
+	// sampledTrace reports whether the trace of a context is sampled.
+	sampledTrace func(ctx context.Context) bool
+	// sampledTraceLevel is the level of entries of sampled traces.
+	sampledTraceLevel Level
+	// zapOptions are applied to the zap logger after the options of this package.
+	zapOptions []zap.Option
+	// development makes invalid key-value pairs panic through DPanic.
+	development bool
+	// consoleEncoder overrides encoder for console outputs.
+	consoleEncoder Encoder
+	// fileEncoder overrides encoder for file outputs.
+	fileEncoder Encoder
+	// encoderFactory creates the encoder instead of encoder, if set.
+	encoderFactory func(zapcore.EncoderConfig) zapcore.Encoder
+	// color renders colored levels on the console when stdout is a terminal.
+	color bool
+	// rollingFormat is the time layout rolling files roll by, empty means HourlyRolling.
+	rollingFormat RollingFormat
+	// levelRollingFormats overrides the rolling format of the files of levels.
+	levelRollingFormats map[Level]RollingFormat
+	// lazyFlush is the idle period after which the flush goroutines of rolling files stop, 0 keeps them running.
+	lazyFlush time.Duration
+	// middlewares is applied to every entry before writing it.
+	middlewares []Middleware
 }
 
 // Level Get log level.
@@ -51,6 +216,80 @@ func (o Options) Level() Level {
 	return o.level
 }
 
+// rollingFormatOf returns the rolling format of the file at p, the override
+// of the lowest level written to it, if any.
+func (o Options) rollingFormatOf(p string) RollingFormat {
+	if o.filename == "" && o.singleFile == "" {
+		for _, lv := range levels {
+			format, ok := o.levelRollingFormats[lv]
+			if ok && path.Join(o.basePath, o.levelFilename(lv)) == p {
+				return format
+			}
+		}
+	}
+	if o.rollingFormat != "" {
+		return o.rollingFormat
+	}
+	return HourlyRolling
+}
+
+// hasLevelFile reports whether entries of lv are written to a file of their own.
+func (o Options) hasLevelFile(lv Level) bool {
+	if o.levelFiles == nil {
+		return true
+	}
+	for _, l := range o.levelFiles {
+		if l == lv {
+			return true
+		}
+	}
+	return false
+}
+
+func (o Options) levelFilename(lv Level) string {
+	if filename, ok := o.levelFilenames[lv]; ok {
+		return filename
+	}
+	switch lv {
+	case DebugLevel:
+		return debugFilename
+	case InfoLevel:
+		return infoFilename
+	case WarnLevel:
+		return warnFilename
+	case ErrorLevel:
+		return errorFilename
+	default:
+		return fatalFilename
+	}
+}
+
+var (
+	defaultOptionsMutex sync.RWMutex
+	defaultOptions      []Option
+)
+
+// SetDefaultOptions set options applied before the options of every logger
+// created afterwards, and applies them to DefaultLogger, so a wrapper can
+// enforce an encoder, a base path or fields in one place. Options of a
+// previous call are replaced, except those already applied to DefaultLogger.
+func SetDefaultOptions(opts ...Option) error {
+	defaultOptionsMutex.Lock()
+	defaultOptions = append([]Option{}, opts...)
+	defaultOptionsMutex.Unlock()
+	return DefaultLogger.Init(opts...)
+}
+
+type bufferedOutputOptions struct {
+	size          int
+	flushInterval time.Duration
+}
+
+type sampling struct {
+	tick              time.Duration
+	first, thereafter int
+}
+
 func newOptions(opts ...Option) Options {
 	opt := Options{
 		level:       InfoLevel,
@@ -72,10 +311,21 @@ func newOptions(opts ...Option) Options {
 			EncodeDuration: zapcore.StringDurationEncoder,
 			EncodeName:     zapcore.FullNameEncoder,
 		},
-		fields:  make(map[string]interface{}),
-		encoder: JsonEncoder,
+		fields:   make(map[string]interface{}),
+		encoder:  JsonEncoder,
+		fileExt:  defaultFileExt,
+		fileMode: defaultFileMode,
+		dirMode:  defaultDirMode,
+
+		flushThreshold: logPageCacheByteSize,
+		flushInterval:  defaultFlushInterval,
 	}
 
+	defaultOptionsMutex.RLock()
+	for _, o := range defaultOptions {
+		o(&opt)
+	}
+	defaultOptionsMutex.RUnlock()
 	for _, o := range opts {
 		o(&opt)
 	}
@@ -107,7 +357,7 @@ const (
 // WithLevel set base path.
 func WithLevel(lv Level) Option {
 	return func(o *Options) {
-		o.level = lv
+		o.level, o.levelErr = lv, nil
 	}
 }
 
@@ -180,3 +430,696 @@ func WithNamespace(name string) Option {
 		o.namespace = name
 	}
 }
+
+// WithCompression compress rolled files with the given algorithm and level,
+// level 0 means the default level of the algorithm.
+func WithCompression(c Compression, level int) Option {
+	return func(o *Options) {
+		o.compression = c
+		o.compressionLevel = level
+	}
+}
+
+// WithFileExt set extension of log files, e.g. "jsonl" or ".txt".
+func WithFileExt(ext string) Option {
+	return func(o *Options) {
+		o.fileExt = ext
+	}
+}
+
+// WithFileMode set permission bits of log files, e.g. 0640.
+func WithFileMode(mode os.FileMode) Option {
+	return func(o *Options) {
+		o.fileMode = mode
+	}
+}
+
+// WithDirMode set permission bits of log directories, e.g. 0750.
+func WithDirMode(mode os.FileMode) Option {
+	return func(o *Options) {
+		o.dirMode = mode
+	}
+}
+
+// WithCallerTrimPrefixes shorten caller paths by removing everything up to and
+// including the first matched prefix, e.g. "vendor/" or "/go/src/".
+func WithCallerTrimPrefixes(prefixes ...string) Option {
+	return func(o *Options) {
+		o.callerTrimPrefixes = append(o.callerTrimPrefixes, prefixes...)
+	}
+}
+
+// WithCallerStripPatterns drop the caller field when the caller path contains
+// one of the patterns, e.g. ".pb.go" for generated code.
+func WithCallerStripPatterns(patterns ...string) Option {
+	return func(o *Options) {
+		o.callerStripPatterns = append(o.callerStripPatterns, patterns...)
+	}
+}
+
+// WithCallerSkipPackages skip caller frames belonging to the given import
+// paths, so the caller points at the code calling into the wrapper packages.
+func WithCallerSkipPackages(packages ...string) Option {
+	return func(o *Options) {
+		o.callerSkipPackages = append(o.callerSkipPackages, packages...)
+	}
+}
+
+// WithSymlink maintain a symlink such as basePath/info.log always pointing at
+// the active rolled file, so operators can tail a stable path.
+func WithSymlink(enable bool) Option {
+	return func(o *Options) {
+		o.symlink = enable
+	}
+}
+
+// WithStringInterning intern the string values of the fields of options and
+// WithFields, up to size distinct values, so long-lived fields of loggers
+// derived again and again, such as service names or routes, share one
+// allocation. Fields of entries aren't interned, high-cardinality values
+// such as request IDs would fill the table.
+func WithStringInterning(size int) Option {
+	return func(o *Options) {
+		o.interner = newInterner(size)
+	}
+}
+
+// WithBufferPoolSize use a dedicated pool of size buffers for every rolling
+// file instead of the package wide shared pool.
+func WithBufferPoolSize(size int) Option {
+	return func(o *Options) {
+		o.bufferPoolSize = size
+	}
+}
+
+// WithFlushThreshold set bytes buffered before a buffer is flushed to disk,
+// larger values trade memory for fewer writes. default is 4096.
+func WithFlushThreshold(size int) Option {
+	return func(o *Options) {
+		o.flushThreshold = size
+	}
+}
+
+// WithOverflowPolicy set what rolling files do when the buffer pool is
+// exhausted: drop the entry (default), block the caller or write directly.
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(o *Options) {
+		o.overflowPolicy = policy
+	}
+}
+
+// WithEntryID stamp every entry with a unique ID under key, the same ID is
+// written to all outputs so entries can be deduplicated and reconciled.
+func WithEntryID(key string, generator IDGenerator) Option {
+	return func(o *Options) {
+		o.idKey = key
+		o.idGenerator = generator
+	}
+}
+
+// WithMonotonicTime guarantee non-decreasing timestamps per output, entries
+// logged after a clock regression are stamped 1ns after the previous entry.
+func WithMonotonicTime(enable bool) Option {
+	return func(o *Options) {
+		o.monotonicTime = enable
+	}
+}
+
+// WithErrorHandler set callback invoked when rolling files fail to write,
+// sync or compress, so applications learn about lost logs.
+func WithErrorHandler(fn func(error)) Option {
+	return func(o *Options) {
+		o.errorHandler = fn
+	}
+}
+
+// WithDiskFullFallback write logs to stderr while the disk is full instead
+// of discarding them, writing to disk is retried periodically.
+func WithDiskFullFallback(enable bool) Option {
+	return func(o *Options) {
+		o.diskFullFallback = enable
+	}
+}
+
+// WithConsoleProfile render time and levels of the console encoder with the
+// given profile, e.g. ZhCNConsoleProfile. json output is not affected.
+func WithConsoleProfile(profile ConsoleProfile) Option {
+	return func(o *Options) {
+		o.consoleProfile = &profile
+	}
+}
+
+// WithRollingFunc set custom rolling layout, e.g. weekly rolling or flat
+// naming, instead of the hourly directory layout.
+func WithRollingFunc(fn RollingFunc) Option {
+	return func(o *Options) {
+		o.rollingFunc = fn
+	}
+}
+
+// WithMaxSize roll files when they exceed size bytes, in addition to rolling
+// when the time fragment changes. 0 disables size based rolling.
+func WithMaxSize(size int64) Option {
+	return func(o *Options) {
+		o.maxSize = size
+	}
+}
+
+// WithPipeline add an output whose entries pass the transforms of the
+// pipeline, e.g. redacted entries for a remote sink.
+func WithPipeline(p Pipeline) Option {
+	return func(o *Options) {
+		o.pipelines = append(o.pipelines, p)
+	}
+}
+
+// WithRollingLocation compute directory fragments and file suffixes of
+// rolling files in loc instead of local time.
+func WithRollingLocation(loc *time.Location) Option {
+	return func(o *Options) {
+		o.rollingLocation = loc
+	}
+}
+
+// WithUTC compute directory fragments and file suffixes of rolling files in
+// UTC, so containers running in different zones agree on file names.
+func WithUTC() Option {
+	return WithRollingLocation(time.UTC)
+}
+
+// WithLevelFilenames set file names of levels, levels mapped to the same name
+// are written to one file, e.g. {ErrorLevel: "err", FatalLevel: "err"}.
+// Missing levels keep their default file names.
+func WithLevelFilenames(filenames map[Level]string) Option {
+	return func(o *Options) {
+		o.levelFilenames = filenames
+	}
+}
+
+// WithFlushInterval set interval buffered data is flushed to disk at, shorter
+// intervals reduce latency, longer ones reduce syscalls. default is 500ms.
+func WithFlushInterval(d time.Duration) Option {
+	return func(o *Options) {
+		o.flushInterval = d
+	}
+}
+
+// WithSyncPolicy set when flushed data is fsynced: on every flush (default),
+// at most once per interval, or never, trading durability for throughput.
+func WithSyncPolicy(policy SyncPolicy, interval time.Duration) Option {
+	return func(o *Options) {
+		o.syncPolicy = policy
+		o.syncInterval = interval
+	}
+}
+
+// WithWatchInterval check every interval whether rolling files were deleted
+// or moved by someone else and reopen them, 0 disables checking.
+func WithWatchInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.watchInterval = interval
+	}
+}
+
+// WithFileLock hold an advisory flock around every buffer written to rolling
+// files, for processes such as preforked workers sharing the same basePath.
+func WithFileLock(enable bool) Option {
+	return func(o *Options) {
+		o.fileLock = enable
+	}
+}
+
+// WithDualFormat render console outputs as colored text while a json copy of
+// the same entries is written to w, such as a file or a pipe, so entries are
+// readable locally and still captured as structured data.
+func WithDualFormat(w io.Writer) Option {
+	return func(o *Options) {
+		o.jsonCopy = zapcore.AddSync(w)
+	}
+}
+
+// WithOnRotated call fn in the background with the path of every rotated and
+// compressed file, e.g. to ship archives off-host with UploadRotated.
+func WithOnRotated(fn func(path string) error) Option {
+	return func(o *Options) {
+		o.onRotated = fn
+	}
+}
+
+// WithOnBeforeRotate call fn right before a rolling file is closed by
+// rotation, w appends to it, e.g. to write trailer records.
+func WithOnBeforeRotate(fn func(path string, w io.Writer)) Option {
+	return func(o *Options) {
+		o.beforeRotate = fn
+	}
+}
+
+// WithOnAfterRotate call fn right after rotation opened the new file, e.g. to
+// emit metrics or trigger ingestion of the closed file.
+func WithOnAfterRotate(fn func(oldPath, newPath string)) Option {
+	return func(o *Options) {
+		o.afterRotate = fn
+	}
+}
+
+// WithFlatLayout name rolled files such as info-2006010215.log in basePath
+// instead of nesting them like 200601/02/info_15.log, which makes path globs
+// of log collectors simpler.
+func WithFlatLayout(enable bool) Option {
+	return func(o *Options) {
+		o.flatLayout = enable
+	}
+}
+
+// WithRoutingRules route entries to the named pipelines by their level and
+// fields, e.g. errors or entries with "security"=true to a SIEM pipeline.
+func WithRoutingRules(rules ...RoutingRule) Option {
+	return func(o *Options) {
+		o.routingRules = append(o.routingRules, rules...)
+	}
+}
+
+// WithFilenameTemplate name rolled files by a template relative to basePath,
+// such as "{name}-{date}-{host}.{ext}", so instances sharing a volume don't
+// collide. Placeholders are {name}, {date}, {ext}, {host} and {pid}.
+func WithFilenameTemplate(template string) Option {
+	return func(o *Options) {
+		o.filenameTemplate = template
+	}
+}
+
+// WithShardField additionally write entries carrying the field key to one
+// rolling file per value of the field, such as basePath/job_id/42, keeping at
+// most maxOpen files open and closing the least recently used ones beyond
+// that. 0 means 64 files.
+func WithShardField(key string, maxOpen int) Option {
+	return func(o *Options) {
+		o.shardKey = key
+		o.maxOpenShards = maxOpen
+	}
+}
+
+// WithOnPressure call fn when a rolling file enters or leaves a degraded
+// state, such as its buffers filling up faster than they're flushed, so the
+// application can shed optional logging before entries are dropped.
+func WithOnPressure(fn func(degraded bool)) Option {
+	return func(o *Options) {
+		o.onPressure = fn
+	}
+}
+
+// WithRotator create the writers of log files with rotator instead of
+// RollingFile, e.g. to plug lumberjack. Options configuring rolling files
+// don't apply to them.
+func WithRotator(rotator Rotator) Option {
+	return func(o *Options) {
+		o.rotator = rotator
+	}
+}
+
+// WithPreallocate reserve size bytes of disk space for every new rolled file
+// on linux, such as the expected size of an hour of logs, reducing
+// fragmentation and running out of space in the middle of a file.
+func WithPreallocate(size int64) Option {
+	return func(o *Options) {
+		o.preallocSize = size
+	}
+}
+
+// WithSequence stamp the entries of every log file with a field key holding
+// an increasing sequence number, persisted next to the file across restarts,
+// so consumers can detect lost and duplicated entries. Numbers are reserved
+// by blocks, a crash skips the rest of the block; calling Sync before exiting
+// continues without gaps.
+func WithSequence(key string) Option {
+	return func(o *Options) {
+		o.sequenceKey = key
+	}
+}
+
+// WithCompressionDelay compress rolled files only after a grace period, such
+// as 10 minutes into the new day, as late writers may still append to them
+// around rollover. Files pending compression when the process exits are left
+// uncompressed.
+func WithCompressionDelay(d time.Duration) Option {
+	return func(o *Options) {
+		o.compressionDelay = d
+	}
+}
+
+// WithResource attach the attributes of the resource producing logs, such as
+// service.name or k8s.pod.name, detected by detectors when the logger is
+// built, to every entry.
+func WithResource(detectors ...ResourceDetector) Option {
+	return func(o *Options) {
+		o.resourceDetectors = append(o.resourceDetectors, detectors...)
+	}
+}
+
+// WithDirectWrite write every entry straight to the rolling file, bypassing
+// buffers, and fsync it as well if sync is true, so an entry is persisted
+// when the logging call returns, e.g. for audit logs.
+func WithDirectWrite(enable, sync bool) Option {
+	return func(o *Options) {
+		o.directWrite = enable
+		o.directSync = sync
+	}
+}
+
+// WithFileHeader write a json header line holding the host name, pid,
+// creation time and fields, such as the app and schema version, at the top of
+// every new rolled file, so processors can attribute files by their content.
+func WithFileHeader(fields map[string]interface{}) Option {
+	return func(o *Options) {
+		o.fileHeader = fields
+	}
+}
+
+// WithManifest write a manifest with the name, size and sha256 of every
+// rotated file next to it, so shipping pipelines can detect truncated or
+// corrupted files, see VerifyManifest.
+func WithManifest(enable bool) Option {
+	return func(o *Options) {
+		o.manifest = enable
+	}
+}
+
+// WithMaxTotalSize cap the bytes taken by the files of each output under
+// basePath, the oldest rolled files are removed after rotation while the cap
+// is exceeded, so a noisy service can't fill a shared disk. Files of other
+// outputs or applications in the directory are left alone.
+func WithMaxTotalSize(size int64) Option {
+	return func(o *Options) {
+		o.maxTotalSize = size
+	}
+}
+
+// WithWriteRateLimit throttle how fast rolling files write to disk, so error
+// storms don't starve co-located services of I/O. Buffers fill up meanwhile
+// and are handled by the overflow policy.
+func WithWriteRateLimit(bytesPerSec int64) Option {
+	return func(o *Options) {
+		o.writeRateLimit = bytesPerSec
+	}
+}
+
+// WithSingleFile write entries of all levels to one rolling file named
+// filename instead of a file per level, relying on the level field to tell
+// them apart, which takes one file descriptor and flush goroutine instead of
+// five.
+func WithSingleFile(filename string) Option {
+	return func(o *Options) {
+		o.singleFile = filename
+	}
+}
+
+// WithLazyFlush start the flush goroutine of a rolling file by the first
+// write to it, and stop it once nothing was written for idle, the next write
+// starts it again. It keeps short-lived processes such as CLIs and tests free
+// of idle goroutines.
+func WithLazyFlush(idle time.Duration) Option {
+	return func(o *Options) {
+		o.lazyFlush = idle
+	}
+}
+
+// WithColor render colored capital levels on the console when the console
+// encoder is used and stdout is a terminal, output piped or redirected to a
+// file stays plain. Files are not affected.
+func WithColor(enable bool) Option {
+	return func(o *Options) {
+		o.color = enable
+	}
+}
+
+// WithStacktraceLevel include a stack trace in entries at or above lv, under
+// the stack key of the encoder config. It's independent from caller
+// reporting.
+func WithStacktraceLevel(lv Level) Option {
+	return func(o *Options) {
+		o.stacktraceLevel = lv
+	}
+}
+
+// WithDevelopment set the options of local development in one go, like
+// zap.NewDevelopment: debug level, colored console output, callers with full
+// paths, stack traces of warnings, no sampling, and DPanic entries, such as
+// the ones reporting invalid key-value pairs, panic.
+func WithDevelopment() Option {
+	return func(o *Options) {
+		o.development = true
+		o.level = DebugLevel
+		o.console = true
+		o.color = true
+		o.encoder = ConsoleEncoder
+		o.stacktraceLevel = WarnLevel
+		o.sampling = sampling{}
+		WithCaller(CallerFull)(o)
+	}
+}
+
+// WithErrorOutput send internal errors of zap, such as entries failing to be
+// encoded or written, to ws instead of stderr, which containers often
+// discard.
+func WithErrorOutput(ws zapcore.WriteSyncer) Option {
+	return func(o *Options) {
+		o.errorOutput = ws
+	}
+}
+
+// WithExitFunc call exit instead of os.Exit after a fatal entry was written
+// and the outputs were flushed, to run cleanup first or to assert fatal paths
+// in tests. Fatal returns if exit does.
+func WithExitFunc(exit func(code int)) Option {
+	return func(o *Options) {
+		o.exitFunc = exit
+	}
+}
+
+// WithClock tell the time of entries and of rolling file names with clock,
+// so that tests can simulate hour boundaries and frozen-time environments log
+// correct timestamps.
+func WithClock(clock zapcore.Clock) Option {
+	return func(o *Options) {
+		o.clock = clock
+	}
+}
+
+// WithFatalHook run hook after a fatal entry was written and the outputs
+// were flushed, before the process exits, e.g. to flush metrics or to send a
+// last-gasp alert. Exiting is left to WithExitFunc.
+func WithFatalHook(hook zapcore.CheckWriteHook) Option {
+	return func(o *Options) {
+		o.fatalHook = hook
+	}
+}
+
+// WithRollingFormat roll files by format instead of HourlyRolling, e.g.
+// DailyRolling for services with low volume to keep fewer files.
+func WithRollingFormat(format RollingFormat) Option {
+	return func(o *Options) {
+		o.rollingFormat = format
+	}
+}
+
+// WithLevelRollingFormats override the rolling format of the files of levels,
+// e.g. {DebugLevel: HourlyRolling} along WithRollingFormat(DailyRolling).
+// Levels sharing a file use the override of the lowest one.
+func WithLevelRollingFormats(formats map[Level]RollingFormat) Option {
+	return func(o *Options) {
+		o.levelRollingFormats = formats
+	}
+}
+
+// WithMaxMessageSize truncate messages longer than n bytes, such as payload
+// bodies logged by accident, appending a "...(truncated X bytes)" marker.
+// Fields are not affected.
+func WithMaxMessageSize(n int) Option {
+	return func(o *Options) {
+		o.maxMessageSize = n
+	}
+}
+
+// WithLevelString set the level parsed from s, such as "warn", read from a
+// config file or a flag. An unknown level keeps the current one, New writes
+// the error to the error output and NewWithError returns it, unless a later
+// WithLevel or WithLevelString sets the level.
+func WithLevelString(s string) Option {
+	return func(o *Options) {
+		lv, err := ParseLevel(s)
+		if err != nil {
+			o.levelErr = err
+			return
+		}
+		o.level, o.levelErr = lv, nil
+	}
+}
+
+// WithCaller set how the caller of entries is reported, e.g. CallerOff to
+// skip capturing callers or CallerFullWithFunction to include the function
+// name.
+func WithCaller(mode CallerMode) Option {
+	return func(o *Options) {
+		o.callerMode = mode
+		o.encoderConfig.FunctionKey = ""
+		switch mode {
+		case CallerShort:
+			o.encoderConfig.EncodeCaller = zapcore.ShortCallerEncoder
+		case CallerFull:
+			o.encoderConfig.EncodeCaller = zapcore.FullCallerEncoder
+		case CallerFullWithFunction:
+			o.encoderConfig.EncodeCaller = zapcore.FullCallerEncoder
+			o.encoderConfig.FunctionKey = "func"
+		}
+	}
+}
+
+// WithConsoleEncoder encode console outputs with encoder instead of the one
+// set by WithEncoder, e.g. readable console output while files stay json.
+func WithConsoleEncoder(encoder Encoder) Option {
+	return func(o *Options) {
+		o.consoleEncoder = encoder
+	}
+}
+
+// WithFileEncoder encode file outputs, including shards, with encoder
+// instead of the one set by WithEncoder.
+func WithFileEncoder(encoder Encoder) Option {
+	return func(o *Options) {
+		o.fileEncoder = encoder
+	}
+}
+
+// WithRuntimeFields attach the hostname, pid, go_version and app_version
+// fields to every entry. The app version is version, or the version of the
+// main module from the build info if empty.
+func WithRuntimeFields(version string) Option {
+	return func(o *Options) {
+		o.runtimeFields = true
+		o.appVersion = version
+	}
+}
+
+// WithDisableCaller skip capturing the caller of entries, runtime.Caller is
+// costly on hot paths. It's WithCaller(CallerOff).
+func WithDisableCaller() Option {
+	return WithCaller(CallerOff)
+}
+
+// WithLineEnding end entries with ending instead of "\n", such as "\r\n" or
+// a record separator. Replay and Query read newline separated files only.
+func WithLineEnding(ending string) Option {
+	return func(o *Options) {
+		o.encoderConfig.LineEnding = ending
+	}
+}
+
+// WithZapOptions apply zap options, such as hooks or core wrappers, that this
+// package doesn't wrap. They are applied after the options of this package
+// and override them.
+func WithZapOptions(opts ...zap.Option) Option {
+	return func(o *Options) {
+		o.zapOptions = append(o.zapOptions, opts...)
+	}
+}
+
+// WithLevelFiles create files for levels only instead of a file per level,
+// entries of the other levels aren't written to disk. e.g. WithLevelFiles(
+// InfoLevel, ErrorLevel) when debug entries are never enabled.
+func WithLevelFiles(levels ...Level) Option {
+	return func(o *Options) {
+		o.levelFiles = append([]Level{}, levels...)
+	}
+}
+
+// WithEncoderFactory encode entries with the encoder created by factory from
+// the encoder config instead of the one set by WithEncoder, e.g. a format of
+// your own. Outputs with an encoder set by WithConsoleEncoder, WithFileEncoder
+// or WithDualFormat keep it.
+func WithEncoderFactory(factory func(zapcore.EncoderConfig) zapcore.Encoder) Option {
+	return func(o *Options) {
+		o.encoderFactory = factory
+	}
+}
+
+// WithSampling write the first entries with the same level and message each
+// tick, then every thereafter-th one, like zapcore.NewSamplerWithOptions,
+// which bounds the cost of hot paths logging the same message.
+func WithSampling(tick time.Duration, first, thereafter int) Option {
+	return func(o *Options) {
+		o.sampling = sampling{tick: tick, first: first, thereafter: thereafter}
+	}
+}
+
+// Production set the options of services in production in one go, like
+// zap.NewProduction: info level, json console output without color, stack
+// traces of errors, and entries with the same message sampled at 100 per
+// second, then every 100th one.
+func Production() Option {
+	return func(o *Options) {
+		o.development = false
+		o.level = InfoLevel
+		o.console = true
+		o.color = false
+		o.encoder = JsonEncoder
+		o.stacktraceLevel = ErrorLevel
+		WithSampling(time.Second, 100, 100)(o)
+		WithCaller(CallerShort)(o)
+	}
+}
+
+// Development is WithDevelopment, the counterpart of Production.
+func Development() Option {
+	return WithDevelopment()
+}
+
+// WithBufferedOutput buffer up to size bytes of console and file outputs,
+// flushed every flushInterval, so that writing a line isn't a syscall under
+// heavy load. Zero values use the defaults of zapcore.BufferedWriteSyncer,
+// 256 kB and 30 seconds. Entries still buffered are lost on a crash, Sync and
+// Close flush them.
+func WithBufferedOutput(size int, flushInterval time.Duration) Option {
+	return func(o *Options) {
+		if size == 0 {
+			size = 256 * 1024
+		}
+		o.bufferedOutput = bufferedOutputOptions{size: size, flushInterval: flushInterval}
+	}
+}
+
+// WithBaggageFields add the baggage members keys returned by source for the
+// context of entries as fields, so attributes propagated across services,
+// such as a customer tier, are logged by every service alike. Members
+// missing from the context are skipped.
+func WithBaggageFields(source BaggageSource, keys ...string) Option {
+	return func(o *Options) {
+		o.baggageSource = source
+		o.baggageKeys = append([]string{}, keys...)
+	}
+}
+
+// WithContextKeys add the values of keys carried by the context of entries
+// as fields, enriching entries of code storing values in contexts already.
+// Fields are named after the String method of keys, or keys of a string
+// type, other keys are reported by Validate and skipped. Keys missing from
+// the context are skipped.
+func WithContextKeys(keys ...interface{}) Option {
+	return func(o *Options) {
+		o.contextKeys = append([]interface{}{}, keys...)
+	}
+}
+
+// WithSampledTraceLevel log entries at or above lv, whatever the level of
+// the logger, when sampled reports the trace of their context is sampled,
+// so sampled traces come with verbose entries while the volume of the others
+// stays low. With OpenTelemetry:
+//
+//	WithSampledTraceLevel(DebugLevel, func(ctx context.Context) bool {
+//		return trace.SpanContextFromContext(ctx).IsSampled()
+//	})
+func WithSampledTraceLevel(lv Level, sampled func(ctx context.Context) bool) Option {
+	return func(o *Options) {
+		o.sampledTraceLevel = lv
+		o.sampledTrace = sampled
+	}
+}
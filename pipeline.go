@@ -0,0 +1,171 @@
+package logger
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ErrWriteTimeout is an error that indicates an output didn't accept an entry before its deadline.
+var ErrWriteTimeout = errors.New("write to output timed out")
+
+// Transform rewrites an entry and its fields before it reaches the output of
+// a Pipeline, returning false drops the entry.
+type Transform func(ent *zapcore.Entry, fields []zapcore.Field) ([]zapcore.Field, bool)
+
+// Pipeline is an additional output whose entries pass a chain of transforms,
+// e.g. a SIEM sink receiving redacted entries while local files keep full
+// fidelity.
+type Pipeline struct {
+	// Name identifies the pipeline in routing rules.
+	Name string
+	// Output is where encoded entries are written.
+	Output zapcore.WriteSyncer
+	// Encoder encodes entries, nil means the encoder of the logger.
+	Encoder zapcore.Encoder
+	// Level is the minimum level of entries, 0 means the level of the logger.
+	Level Level
+	// Transforms are applied in order before encoding.
+	Transforms []Transform
+	// WriteTimeout bounds how long a logging call waits for Output, the
+	// deadline of the context the entry is logged with applies as well.
+	// The call returns ErrWriteTimeout once it's over: an entry still waiting
+	// for a previous write is dropped, but a write already started goes on in
+	// the background and may still reach Output.
+	WriteTimeout time.Duration
+}
+
+// pipelineCore applies transforms to the context fields as well, so context
+// fields are kept aside instead of being encoded by With.
+type pipelineCore struct {
+	zapcore.Core
+	fields       []zapcore.Field
+	transforms   []Transform
+	writeTimeout time.Duration
+	// slot is held by the write in flight, so a hanging output blocks at
+	// most one goroutine.
+	slot chan struct{}
+}
+
+func (l *logger) buildPipelines() []zapcore.Core {
+	cores := make([]zapcore.Core, 0, len(l.opt.pipelines))
+	for _, p := range l.opt.pipelines {
+		enc := p.Encoder
+		if enc == nil {
+			enc = l.buildEncoder(l.opt)
+		}
+		var enabler zapcore.LevelEnabler = l.atomicLevel
+		if p.Level != 0 {
+			enabler = p.Level.unmarshalZapLevel()
+		}
+		transforms := p.Transforms
+		if route := compileRoutes(l.opt.routingRules, p.Name); route != nil {
+			transforms = append([]Transform{route}, transforms...)
+		}
+		var core zapcore.Core = &pipelineCore{
+			Core:         newMonotonicCore(zapcore.NewCore(enc, p.Output, enabler), newMonotonicClock(l.opt)),
+			transforms:   transforms,
+			writeTimeout: p.WriteTimeout,
+			slot:         make(chan struct{}, 1),
+		}
+		if p.Level != 0 {
+			core = &levelCore{Core: core, match: enabler.Enabled}
+		}
+		cores = append(cores, core)
+	}
+	return cores
+}
+
+func (c *pipelineCore) With(fields []zapcore.Field) zapcore.Core {
+	_copy := *c
+	_copy.fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	return &_copy
+}
+
+func (c *pipelineCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *pipelineCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ctx, fields := extractContext(fields)
+	all := make([]zapcore.Field, 0, len(c.fields)+len(fields))
+	all = append(append(all, c.fields...), fields...)
+
+	var ok bool
+	for _, transform := range c.transforms {
+		if all, ok = transform(&ent, all); !ok {
+			return nil
+		}
+	}
+	return c.writeWithDeadline(ctx, ent, all)
+}
+
+// writeWithDeadline writes ent unless the deadline passes first. Writes are
+// done one at a time, a write started before the deadline isn't interrupted:
+// the entry may still be written after ErrWriteTimeout is returned.
+func (c *pipelineCore) writeWithDeadline(ctx context.Context, ent zapcore.Entry, fields []zapcore.Field) error {
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	if done == nil && c.writeTimeout <= 0 {
+		return c.Core.Write(ent, fields)
+	}
+
+	var timeout <-chan time.Time
+	if c.writeTimeout > 0 {
+		t := time.NewTimer(c.writeTimeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case c.slot <- struct{}{}:
+	case <-done:
+		return ErrWriteTimeout
+	case <-timeout:
+		return ErrWriteTimeout
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		defer func() { <-c.slot }()
+		errc <- c.Core.Write(ent, fields)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-done:
+		return ErrWriteTimeout
+	case <-timeout:
+		return ErrWriteTimeout
+	}
+}
+
+// Redact is a Transform replacing the values of fields with the given keys.
+func Redact(keys ...string) Transform {
+	return func(ent *zapcore.Entry, fields []zapcore.Field) ([]zapcore.Field, bool) {
+		for i := range fields {
+			if fields[i].Type != zapcore.NamespaceType && containsString(keys, fields[i].Key) {
+				fields[i] = zap.String(fields[i].Key, "***")
+			}
+		}
+		return fields, true
+	}
+}
+
+// Sample is a Transform keeping one entry out of every n.
+func Sample(n int) Transform {
+	var count uint64
+	return func(ent *zapcore.Entry, fields []zapcore.Field) ([]zapcore.Field, bool) {
+		return fields, n <= 1 || (atomic.AddUint64(&count, 1)-1)%uint64(n) == 0
+	}
+}
@@ -0,0 +1,64 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestPipeline(t *testing.T) {
+	var buf bytes.Buffer
+	cfg := newOptions().encoderConfig
+	cfg.TimeKey, cfg.CallerKey = "", ""
+	log := New(
+		WithConsole(false),
+		WithFields(map[string]interface{}{"token": "secret"}),
+		WithPipeline(Pipeline{
+			Output:     zapcore.AddSync(&buf),
+			Encoder:    zapcore.NewJSONEncoder(cfg),
+			Transforms: []Transform{Redact("token", "password"), Sample(2)},
+		}),
+	)
+
+	log.Infow(msg, "password", "123456")
+	log.Info(msg)
+	log.Info(msg)
+	assert.Equal(t, `{"level":"info","msg":"hello there","token":"***","password":"***"}`+"\n"+
+		`{"level":"info","msg":"hello there","token":"***"}`+"\n", buf.String())
+}
+
+type blockingSyncer struct {
+	release chan struct{}
+}
+
+func (s blockingSyncer) Write(b []byte) (int, error) {
+	<-s.release
+	return len(b), nil
+}
+
+func (s blockingSyncer) Sync() error {
+	return nil
+}
+
+func TestPipeline_WriteTimeout(t *testing.T) {
+	out := blockingSyncer{release: make(chan struct{})}
+	defer close(out.release)
+
+	log := New(
+		WithConsole(false),
+		WithPipeline(Pipeline{Output: out, WriteTimeout: 10 * time.Millisecond}),
+	)
+	start := time.Now()
+	log.Info(msg)
+	log.Info(msg)
+	assert.Less(t, time.Since(start), time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	log.WithContext(ctx).Info(msg)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"sync/atomic"
+)
+
+const (
+	// the file is degraded when more of the buffer pool is in use, and
+	// recovers when less is in use, so the state doesn't flap.
+	pressureHigh = 0.8
+	pressureLow  = 0.5
+)
+
+// SetOnPressure : Call fn when the file enters or leaves a degraded state, in
+// which the buffer pool is more than 80% in use, the flusher lags behind or
+// data is spilled to disk, so applications can shed optional logging
+func (r *RollingFile) SetOnPressure(fn func(degraded bool)) {
+	r.rollMutex.Lock()
+	r.onPressure = fn
+	r.rollMutex.Unlock()
+}
+
+// Degraded : Whether the file is in a degraded state
+func (r *RollingFile) Degraded() bool {
+	return atomic.LoadInt32(&r.degraded) == 1
+}
+
+// checkPressure updates the degraded state and notifies the pressure handler
+// on transitions.
+func (r *RollingFile) checkPressure() {
+	r.rollMutex.RLock()
+	fn := r.onPressure
+	r.rollMutex.RUnlock()
+	if fn == nil {
+		return
+	}
+
+	pool := r.bufferPool()
+	usage := float64(atomic.LoadInt64(&pool.count)) / float64(pool.size)
+	lagging := len(r.fullBuffer) == cap(r.fullBuffer) || atomic.LoadInt32(&r.spillPending) == 1
+	if usage > pressureHigh || lagging {
+		if atomic.CompareAndSwapInt32(&r.degraded, 0, 1) {
+			fn(true)
+		}
+	} else if usage < pressureLow {
+		if atomic.CompareAndSwapInt32(&r.degraded, 1, 0) {
+			fn(false)
+		}
+	}
+}
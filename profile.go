@@ -0,0 +1,53 @@
+package logger
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// ConsoleProfile customizes how the console encoder renders time and levels,
+// the json encoder is not affected and stays canonical.
+type ConsoleProfile struct {
+	// TimeLayout is the time layout, e.g. "2006-01-02 03:04:05 PM", empty keeps the encoder config.
+	TimeLayout string
+	// LevelLabels is the translated labels of levels, missing levels keep the encoder config.
+	LevelLabels map[Level]string
+}
+
+var (
+	// ZhCNConsoleProfile renders 24h time and chinese level labels.
+	ZhCNConsoleProfile = ConsoleProfile{
+		TimeLayout: "2006年01月02日 15:04:05.000",
+		LevelLabels: map[Level]string{
+			DebugLevel: "调试",
+			InfoLevel:  "信息",
+			WarnLevel:  "警告",
+			ErrorLevel: "错误",
+			FatalLevel: "致命",
+		},
+	}
+
+	// EnUSConsoleProfile renders US dates and 12h time.
+	EnUSConsoleProfile = ConsoleProfile{
+		TimeLayout: "01/02/2006 03:04:05.000 PM",
+	}
+)
+
+// apply returns a copy of cfg rendering time and levels with the profile.
+func (p ConsoleProfile) apply(cfg zapcore.EncoderConfig) zapcore.EncoderConfig {
+	if p.TimeLayout != "" {
+		cfg.EncodeTime = zapcore.TimeEncoderOfLayout(p.TimeLayout)
+	}
+	if len(p.LevelLabels) > 0 {
+		fallback := cfg.EncodeLevel
+		cfg.EncodeLevel = func(lvl zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
+			if label, ok := p.LevelLabels[levelFromZap(lvl)]; ok {
+				enc.AppendString(label)
+				return
+			}
+			if fallback != nil {
+				fallback(lvl, enc)
+			}
+		}
+	}
+	return cfg
+}
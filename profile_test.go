@@ -0,0 +1,24 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestConsoleProfile(t *testing.T) {
+	cfg := ZhCNConsoleProfile.apply(newOptions().encoderConfig)
+	cfg.CallerKey = ""
+	enc := zapcore.NewConsoleEncoder(cfg)
+
+	buf, err := enc.EncodeEntry(zapcore.Entry{
+		Level:   zap.WarnLevel,
+		Time:    time.Date(2024, 3, 5, 14, 4, 5, 0, time.UTC),
+		Message: msg,
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024年03月05日 14:04:05.000\t警告\thello there\n", buf.String())
+}
@@ -0,0 +1,168 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+)
+
+// QuerySpec selects entries of the log files of a logger, zero values match
+// all entries.
+type QuerySpec struct {
+	// Level is the minimum level of entries.
+	Level Level
+	// Since and Until bound the time of entries, Query fails on entries whose
+	// time doesn't parse when either is set.
+	Since time.Time
+	Until time.Time
+	// TimeLayout parses the time of entries, default is the ISO8601 layout of the default encoder config.
+	TimeLayout string
+	// Fields is the values fields of entries must be equal to.
+	Fields map[string]interface{}
+	// Limit is the maximum number of entries returned, 0 means no limit.
+	Limit int
+}
+
+// LogEntry is an entry read from a log file.
+type LogEntry struct {
+	Level   Level
+	Time    time.Time
+	Message string
+	Caller  string
+	Fields  map[string]interface{}
+}
+
+var _ Querier = (*logger)(nil)
+
+// Query scans the active and recently rolled files of the logger line by line
+// and returns the entries matching spec, oldest files first. Only files
+// written by the json encoder are supported.
+func (l *logger) Query(spec QuerySpec) ([]LogEntry, error) {
+	if err := l.Sync(); err != nil {
+		return nil, err
+	}
+	if spec.TimeLayout == "" {
+		spec.TimeLayout = defaultReplayTimeLayout
+	}
+
+	want := make(map[string]interface{}, len(spec.Fields))
+	for k, v := range spec.Fields {
+		// compare values the way they are decoded from files
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var decoded interface{}
+		if err := json.Unmarshal(b, &decoded); err != nil {
+			return nil, err
+		}
+		want[k] = decoded
+	}
+
+	var entries []LogEntry
+	for _, path := range l.recentFiles() {
+		done, err := l.queryFile(path, spec, want, &entries)
+		if err != nil {
+			return entries, err
+		}
+		if done {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// recentFiles returns the paths of the files recently written by the
+// logger, rolled files may have been compressed since.
+func (l *logger) recentFiles() []string {
+	var paths []string
+	for _, w := range l.outputs().writeSyncers {
+		r, ok := unbuffered(w).(*RollingFile)
+		if !ok {
+			continue
+		}
+		for _, cp := range r.Checkpoints() {
+			for _, path := range []string{cp.Path, cp.Path + GzipCompression.Ext(), cp.Path + ZstdCompression.Ext()} {
+				if _, err := os.Stat(path); err == nil {
+					paths = append(paths, path)
+					break
+				}
+			}
+		}
+	}
+	return paths
+}
+
+// queryFile appends the matching entries of a file, it reports whether the
+// limit is reached.
+func (l *logger) queryFile(path string, spec QuerySpec, want map[string]interface{}, entries *[]LogEntry) (bool, error) {
+	f, err := openLogFile(path)
+	if os.IsNotExist(err) {
+		// compressed or removed in the meantime
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	cfg := l.outputs().opt.encoderConfig
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var m map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil || m[fileHeaderKey] == true {
+			continue
+		}
+
+		var ent LogEntry
+		if s, ok := m[cfg.LevelKey].(string); ok {
+			ent.Level, _ = ParseLevel(s)
+		}
+		s, _ := m[cfg.TimeKey].(string)
+		if ent.Time, err = time.Parse(spec.TimeLayout, s); err != nil && spec.bounded() {
+			return false, fmt.Errorf("logger: query %s: time of entries: %w", path, err)
+		}
+		ent.Message, _ = m[cfg.MessageKey].(string)
+		ent.Caller, _ = m[cfg.CallerKey].(string)
+		for _, k := range []string{cfg.LevelKey, cfg.TimeKey, cfg.MessageKey, cfg.CallerKey} {
+			delete(m, k)
+		}
+		ent.Fields = m
+
+		if !spec.match(ent, want) {
+			continue
+		}
+		*entries = append(*entries, ent)
+		if spec.Limit > 0 && len(*entries) >= spec.Limit {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// bounded reports whether spec selects entries by time.
+func (spec QuerySpec) bounded() bool {
+	return !spec.Since.IsZero() || !spec.Until.IsZero()
+}
+
+func (spec QuerySpec) match(ent LogEntry, want map[string]interface{}) bool {
+	if spec.Level != 0 && ent.Level < spec.Level {
+		return false
+	}
+	if !spec.Since.IsZero() && ent.Time.Before(spec.Since) {
+		return false
+	}
+	if !spec.Until.IsZero() && ent.Time.After(spec.Until) {
+		return false
+	}
+	for k, v := range want {
+		if !reflect.DeepEqual(ent.Fields[k], v) {
+			return false
+		}
+	}
+	return true
+}
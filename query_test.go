@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestQuery(t *testing.T) {
+	log := New(
+		WithBasePath(t.TempDir()),
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithFilename("app"),
+	)
+	start := time.Now().Add(-time.Second)
+	log.Infow(msg, "user", "a", "age", 23)
+	log.Warnw(msg, "user", "b", "age", 23)
+	log.Errorw(msg, "user", "a", "age", 24)
+
+	entries, err := log.(Querier).Query(QuerySpec{Level: WarnLevel})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	entries, err = log.(Querier).Query(QuerySpec{Since: start, Fields: map[string]interface{}{"user": "a", "age": 24}})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, Level(ErrorLevel), entries[0].Level)
+	assert.Equal(t, msg, entries[0].Message)
+
+	entries, err = log.(Querier).Query(QuerySpec{Fields: map[string]interface{}{"age": 23}, Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "a", entries[0].Fields["user"])
+
+	entries, err = log.(Querier).Query(QuerySpec{Until: start})
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestQueryTimeLayout(t *testing.T) {
+	cfg := newOptions().encoderConfig
+	cfg.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+	log := New(
+		WithBasePath(t.TempDir()),
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithFilename("app"),
+		WithEncoderConfig(cfg),
+	)
+	start := time.Now().Add(-time.Second)
+	log.Info(msg)
+
+	// entries are kept when not selected by time
+	entries, err := log.(Querier).Query(QuerySpec{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	_, err = log.(Querier).Query(QuerySpec{Since: start})
+	assert.Error(t, err)
+
+	entries, err = log.(Querier).Query(QuerySpec{Since: start, TimeLayout: time.RFC3339Nano})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
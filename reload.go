@@ -0,0 +1,260 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// outputs are the cores of a logger and the writers behind them, built from
+// opt and swapped as a whole when the configuration is reloaded.
+type outputs struct {
+	// writers counts the entries being written, superseded outputs are
+	// closed once none is. First field to be 64-bit aligned.
+	writers int64
+
+	opt  Options
+	core zapcore.Core
+	// debugCore writes the entries of contexts set by WithDebugCtx.
+	debugCore zapcore.Core
+	// root is the zap logger configured by opt, writing to the current
+	// outputs, fields are the fields of opt added by loggers created by New.
+	root   *zap.Logger
+	fields []zap.Field
+	// skipPackages are the packages whose frames are skipped when resolving
+	// the caller of entries.
+	skipPackages []string
+	// writeSyncers and closers are the outputs opened by the logger, writers
+	// passed in the options are left to the caller.
+	writeSyncers []zapcore.WriteSyncer
+	closers      []io.Closer
+	// sequences are the sequences of WithSequence, persisted on close.
+	sequences []*sequence
+}
+
+// outputState holds the current outputs shared by a logger and the loggers
+// derived from it.
+type outputState struct {
+	mutex   sync.Mutex
+	current atomic.Value // *outputs
+}
+
+func (s *outputState) load() *outputs {
+	return s.current.Load().(*outputs)
+}
+
+// reloadCore writes to the current outputs, applying its fields to them again
+// after a reload.
+type reloadCore struct {
+	state  *outputState
+	fields []zapcore.Field
+	cache  *atomic.Value // *reloadCache
+	// debug writes to the debug core of the outputs.
+	debug bool
+}
+
+type reloadCache struct {
+	outputs *outputs
+	core    zapcore.Core
+}
+
+func newReloadCore(state *outputState) *reloadCore {
+	return &reloadCore{state: state, cache: new(atomic.Value)}
+}
+
+func (c *reloadCore) current() zapcore.Core {
+	out := c.state.load()
+	if cached, ok := c.cache.Load().(*reloadCache); ok && cached.outputs == out {
+		return cached.core
+	}
+	core := out.core
+	if c.debug {
+		core = out.debugCore
+	}
+	if len(c.fields) > 0 {
+		core = core.With(c.fields)
+	}
+	c.cache.Store(&reloadCache{outputs: out, core: core})
+	return core
+}
+
+func (c *reloadCore) Enabled(lvl zapcore.Level) bool {
+	return c.current().Enabled(lvl)
+}
+
+func (c *reloadCore) With(fields []zapcore.Field) zapcore.Core {
+	return &reloadCore{
+		state:  c.state,
+		fields: append(c.fields[:len(c.fields):len(c.fields)], fields...),
+		cache:  new(atomic.Value),
+		debug:  c.debug,
+	}
+}
+
+// debugCore returns c writing to the debug core of the outputs.
+func (c *reloadCore) debugCore() *reloadCore {
+	return &reloadCore{state: c.state, fields: c.fields, cache: new(atomic.Value), debug: true}
+}
+
+func (c *reloadCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.current().Check(ent, ce)
+}
+
+func (c *reloadCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.current().Write(ent, fields)
+}
+
+func (c *reloadCore) Sync() error {
+	return c.current().Sync()
+}
+
+// outputs returns the current outputs of l.
+func (l *logger) outputs() *outputs {
+	return l.state.load()
+}
+
+// acquire returns the current outputs of l, which aren't closed until they
+// are released.
+func (l *logger) acquire() *outputs {
+	for {
+		out := l.outputs()
+		atomic.AddInt64(&out.writers, 1)
+		// outputs swapped in the meantime may be closing already
+		if l.outputs() == out {
+			return out
+		}
+		out.release()
+	}
+}
+
+func (out *outputs) release() {
+	atomic.AddInt64(&out.writers, -1)
+}
+
+// drain waits for the entries being written to out, once out is superseded.
+func (out *outputs) drain() {
+	for atomic.LoadInt64(&out.writers) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// rebuild applies opts on top of the current options and swaps the outputs
+// for ones built from the result, then closes the previous outputs once the
+// entries being written to them are.
+func (l *logger) rebuild(opts ...Option) error {
+	l.state.mutex.Lock()
+	defer l.state.mutex.Unlock()
+
+	prev := l.outputs()
+	opt := prev.opt
+	// SetLevel changes the level only, keep it unless opts set one
+	opt.level = levelFromZap(l.atomicLevel.Level())
+	for _, o := range opts {
+		o(&opt)
+	}
+	if err := opt.Validate(); err != nil {
+		return err
+	}
+
+	// cores of level files are enabled by the level when built
+	prevLevel := l.atomicLevel.Level()
+	l.atomicLevel.SetLevel(opt.level.unmarshalZapLevel())
+	// building stores the outputs into the shared state once they're created
+	n := &logger{opt: opt, atomicLevel: l.atomicLevel, state: l.state, bases: new(atomic.Value)}
+	if err := n.build(); err != nil {
+		l.atomicLevel.SetLevel(prevLevel)
+		return err
+	}
+	prev.drain()
+	return closeOutputs(prev.writeSyncers, prev.closers)
+}
+
+// closeOutputs closes the outputs opened by a logger.
+func closeOutputs(writeSyncers []zapcore.WriteSyncer, closers []io.Closer) error {
+	var err error
+	for _, w := range writeSyncers {
+		if c, ok := w.(io.Closer); ok {
+			err = multierr.Append(err, c.Close())
+		}
+	}
+	for _, c := range closers {
+		err = multierr.Append(err, c.Close())
+	}
+	return err
+}
+
+// Watch watches the YAML or JSON config file at path until ctx is done, and
+// reloads l whenever the file changed: the level and the outputs are swapped
+// atomically, entries are written either to the previous or to the new
+// outputs, which are closed once written. Keys removed from the file keep
+// their current values. Errors reading or applying the file leave l unchanged
+// and are passed to onError if not nil.
+//
+// Changes are notified by inotify on Linux, the file is also polled every
+// interval, which is the only check on other platforms.
+func Watch(ctx context.Context, l Logger, path string, interval time.Duration, onError func(error)) error {
+	target, ok := l.(*logger)
+	if !ok {
+		return fmt.Errorf("logger: %T can't be reloaded", l)
+	}
+	if interval <= 0 {
+		return optionError("interval", "interval must be positive, got %s", interval)
+	}
+	last, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	changes, stop, err := watchFile(path)
+	if err != nil {
+		// polled only
+		changes, stop = nil, func() {}
+	}
+	go func() {
+		defer stop()
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+			case <-changes:
+			}
+
+			b, err := os.ReadFile(path)
+			if err == nil && bytes.Equal(b, last) {
+				continue
+			}
+			if err == nil {
+				last = b
+				err = target.reloadConfig(path, b)
+			}
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (l *logger) reloadConfig(path string, b []byte) error {
+	c, err := parseConfig(path, b)
+	if err != nil {
+		return err
+	}
+	opts, err := c.Options()
+	if err != nil {
+		return err
+	}
+	return l.rebuild(opts...)
+}
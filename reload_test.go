@@ -0,0 +1,322 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatch(t *testing.T) {
+	var (
+		mutex   sync.Mutex
+		writers = make(map[string]*memRotator)
+	)
+	rotator := func(path string) (RotatingWriter, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		w := &memRotator{path: path}
+		writers[path] = w
+		return w, nil
+	}
+	writer := func(path string) *memRotator {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return writers[path]
+	}
+
+	path := filepath.Join(t.TempDir(), "logger.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("filename: app\n"), 0644))
+	l := New(WithBasePath("logs"), WithConsole(false), WithDisableDisk(false), WithFilename("app"), WithRotator(rotator))
+	derived := l.WithFields(map[string]interface{}{"job": "sync"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var errs []error
+	assert.NoError(t, Watch(ctx, l, path, 10*time.Millisecond, func(err error) {
+		mutex.Lock()
+		errs = append(errs, err)
+		mutex.Unlock()
+	}))
+
+	assert.NoError(t, os.WriteFile(path, []byte("level: error\nfilename: other\n"), 0644))
+	assert.Eventually(t, func() bool {
+		return writer(filepath.Join("logs", "other")) != nil
+	}, time.Second, 5*time.Millisecond)
+
+	// loggers derived before the reload write to the new outputs, with their fields
+	derived.Info("dropped")
+	derived.Error(msg)
+	out := writer(filepath.Join("logs", "other")).String()
+	assert.NotContains(t, out, "dropped")
+	assert.Contains(t, out, msg)
+	assert.Contains(t, out, `"job":"sync"`)
+	assert.NotContains(t, writer(filepath.Join("logs", "app")).String(), msg)
+
+	// invalid configs leave the logger unchanged
+	assert.NoError(t, os.WriteFile(path, []byte("level: verbose\n"), 0644))
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return len(errs) == 1
+	}, time.Second, 5*time.Millisecond)
+	l.Error(msg)
+	assert.Equal(t, 2, strings.Count(writer(filepath.Join("logs", "other")).String(), "\n"))
+}
+
+func TestDerivedLoggerOutputs(t *testing.T) {
+	l := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(io.Discard))
+	for _, derived := range []Logger{
+		l.WithContext(context.Background()),
+		l.WithFields(map[string]interface{}{"job": "sync"}),
+		l.(FieldReplacer).WithoutFields("job"),
+		l.WithCallDepth(1),
+	} {
+		_, err := derived.(Querier).Query(QuerySpec{})
+		assert.NoError(t, err)
+		assert.NoError(t, derived.(Closer).Close(context.Background()))
+	}
+}
+
+type closeRecorder struct {
+	memRotator
+	closed bool
+}
+
+func (w *closeRecorder) Close() error {
+	w.closed = true
+	return nil
+}
+
+func TestInitKeepsSetLevel(t *testing.T) {
+	var buf syncBuffer
+	l := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf))
+	l.SetLevel(ErrorLevel)
+	assert.NoError(t, l.Init(WithMaxMessageSize(100)))
+	assert.Equal(t, Level(ErrorLevel), l.Options().Level())
+	l.Info("dropped")
+	assert.NotContains(t, buf.String(), "dropped")
+
+	// unless the options set one
+	assert.NoError(t, l.Init(WithLevel(WarnLevel)))
+	assert.Equal(t, Level(WarnLevel), l.Options().Level())
+}
+
+func TestInit(t *testing.T) {
+	writers := make(map[string]*closeRecorder)
+	l := New(WithBasePath("logs"), WithConsole(false), WithDisableDisk(false), WithFilename("app"),
+		WithRotator(func(path string) (RotatingWriter, error) {
+			w := &closeRecorder{}
+			writers[path] = w
+			return w, nil
+		}))
+	derived := l.WithFields(map[string]interface{}{"job": "sync"})
+
+	assert.NoError(t, l.Init(WithFilename("other"), WithFields(map[string]interface{}{"app": "api"})))
+	assert.True(t, writers[filepath.Join("logs", "app")].closed)
+	assert.Equal(t, "other", l.Options().filename)
+
+	l.Info(msg)
+	derived.Info(msg)
+	out := writers[filepath.Join("logs", "other")].String()
+	assert.Equal(t, 2, strings.Count(out, msg))
+	assert.Equal(t, 2, strings.Count(out, `"app":"api"`))
+	assert.Equal(t, 1, strings.Count(out, `"job":"sync"`))
+
+	// invalid options leave the logger unchanged
+	assert.Error(t, l.Init(WithFlushInterval(0)))
+	assert.False(t, writers[filepath.Join("logs", "other")].closed)
+}
+
+func TestInitConcurrentWrites(t *testing.T) {
+	var buf syncBuffer
+	l := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf))
+	derived := l.WithFields(map[string]interface{}{"job": "sync"})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				derived.Info(msg)
+				_ = l.Options()
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, l.Init(WithMaxMessageSize(1024+i)))
+	}
+	wg.Wait()
+	assert.Equal(t, 1024+9, l.Options().maxMessageSize)
+}
+
+func TestInitKeepsCallerWriters(t *testing.T) {
+	var buf syncBuffer
+	l := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf))
+	assert.NoError(t, l.Init(WithFields(map[string]interface{}{"app": "api"})))
+	assert.NoError(t, l.(*logger).Close(context.Background()))
+	assert.False(t, buf.closed)
+
+	l.Info(msg)
+	assert.Contains(t, buf.String(), `"app":"api"`)
+}
+
+func TestInitClosesPartialOutputs(t *testing.T) {
+	var created []*closeRecorder
+	rotator := func(path string) (RotatingWriter, error) {
+		if strings.HasSuffix(path, "error") {
+			return nil, errors.New("no space left")
+		}
+		w := &closeRecorder{}
+		created = append(created, w)
+		return w, nil
+	}
+	l := New(WithConsole(false), WithDisableDisk(true))
+	assert.Error(t, l.Init(WithDisableDisk(false), WithRotator(rotator)))
+	assert.NotEmpty(t, created)
+	for _, w := range created {
+		assert.True(t, w.closed)
+	}
+}
+
+// syncBuffer is a buffer safe for concurrent writes, recording whether it was
+// closed.
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.closed = true
+	return nil
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+func TestWatchNotified(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("config files are polled")
+	}
+	var buf syncBuffer
+	path := filepath.Join(t.TempDir(), "logger.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{}`), 0644))
+	l := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, Watch(ctx, l, path, time.Hour, nil))
+
+	// replaced by a rename, as done by editors
+	tmp := path + ".tmp"
+	assert.NoError(t, os.WriteFile(tmp, []byte(`{"sampling": {"tick": "1m", "first": 1, "thereafter": 0}}`), 0644))
+	assert.NoError(t, os.Rename(tmp, path))
+	assert.Eventually(t, func() bool {
+		return l.Options().sampling.tick == time.Minute
+	}, time.Second, 5*time.Millisecond)
+
+	l.Info(msg)
+	l.Info(msg)
+	assert.Equal(t, 1, strings.Count(buf.String(), msg))
+}
+
+func TestWatchReplaced(t *testing.T) {
+	// notified on Linux, polled elsewhere
+	interval := time.Hour
+	if runtime.GOOS != "linux" {
+		interval = 10 * time.Millisecond
+	}
+	for _, tt := range []struct {
+		name    string
+		replace func(t *testing.T, dir string, b []byte)
+	}{
+		{"recreated", func(t *testing.T, dir string, b []byte) {
+			assert.NoError(t, os.Remove(filepath.Join(dir, "logger.json")))
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, "logger.json"), b, 0644))
+		}},
+		// Kubernetes ConfigMap volumes: logger.json -> ..data/logger.json,
+		// ..data -> a directory of the version, swapped by a rename
+		{"configmap", func(t *testing.T, dir string, b []byte) {
+			assert.NoError(t, os.Mkdir(filepath.Join(dir, "..v2"), 0755))
+			assert.NoError(t, os.WriteFile(filepath.Join(dir, "..v2", "logger.json"), b, 0644))
+			assert.NoError(t, os.Symlink("..v2", filepath.Join(dir, "..data_tmp")))
+			assert.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "logger.json")
+			if tt.name == "configmap" {
+				if runtime.GOOS == "windows" {
+					t.Skip("symlinks require privileges on windows")
+				}
+				assert.NoError(t, os.Mkdir(filepath.Join(dir, "..v1"), 0755))
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, "..v1", "logger.json"), []byte(`{}`), 0644))
+				assert.NoError(t, os.Symlink("..v1", filepath.Join(dir, "..data")))
+				assert.NoError(t, os.Symlink(filepath.Join("..data", "logger.json"), path))
+			} else {
+				assert.NoError(t, os.WriteFile(path, []byte(`{}`), 0644))
+			}
+			l := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(io.Discard))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			assert.NoError(t, Watch(ctx, l, path, interval, nil))
+
+			tt.replace(t, dir, []byte(`{"level": "error"}`))
+			assert.Eventually(t, func() bool {
+				return l.Options().Level() == ErrorLevel
+			}, time.Second, 5*time.Millisecond)
+		})
+	}
+}
+
+func TestInitDrainsWrites(t *testing.T) {
+	var created []*closeRecorder
+	l := New(WithBasePath("logs"), WithConsole(false), WithDisableDisk(false), WithFilename("app"),
+		WithRotator(func(path string) (RotatingWriter, error) {
+			w := &closeRecorder{}
+			created = append(created, w)
+			return w, nil
+		})).(*logger)
+
+	app := created[0]
+	// an entry being written to the outputs
+	out := l.acquire()
+	done := make(chan error)
+	go func() {
+		done <- l.Init(WithFilename("other"))
+	}()
+	select {
+	case <-done:
+		t.Fatal("outputs closed while written")
+	case <-time.After(20 * time.Millisecond):
+	}
+	assert.False(t, app.closed)
+
+	out.release()
+	assert.NoError(t, <-done)
+	assert.True(t, app.closed)
+}
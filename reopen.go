@@ -0,0 +1,78 @@
+package logger
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"go.uber.org/multierr"
+)
+
+// rollingFiles registers open rolling files, so they can be reopened at once
+// when an external tool rotated them.
+var rollingFiles = struct {
+	sync.Mutex
+	m map[*RollingFile]struct{}
+}{m: make(map[*RollingFile]struct{})}
+
+func registerRollingFile(r *RollingFile) {
+	rollingFiles.Lock()
+	rollingFiles.m[r] = struct{}{}
+	rollingFiles.Unlock()
+}
+
+func unregisterRollingFile(r *RollingFile) {
+	rollingFiles.Lock()
+	delete(rollingFiles.m, r)
+	rollingFiles.Unlock()
+}
+
+// ReopenAll reopens every open RollingFile.
+func ReopenAll() error {
+	rollingFiles.Lock()
+	files := make([]*RollingFile, 0, len(rollingFiles.m))
+	for r := range rollingFiles.m {
+		files = append(files, r)
+	}
+	rollingFiles.Unlock()
+
+	var err error
+	for _, r := range files {
+		if rerr := r.Reopen(); rerr != ErrClosedRollingFile {
+			err = multierr.Append(err, rerr)
+		}
+	}
+	return err
+}
+
+// ReopenOnSignal reopens every open RollingFile whenever one of sigs, SIGHUP
+// by default, is received, for logrotate setups that rename files and send
+// a signal. Call stop to stop listening.
+func ReopenOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case <-ch:
+				ReopenAll()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ReplayOptions configures Replay.
+type ReplayOptions struct {
+	// TimeLayout parses the time of entries, default is the ISO8601 layout of the default encoder config.
+	TimeLayout string
+	// RewriteTime rewrites the time of entries, e.g. to shift or replace it.
+	RewriteTime func(t time.Time) time.Time
+}
+
+// ReplayResult is the outcome of Replay.
+type ReplayResult struct {
+	// Replayed is the number of entries re-emitted.
+	Replayed int
+	// Skipped is the number of lines which are not json entries.
+	Skipped int
+}
+
+const defaultReplayTimeLayout = "2006-01-02T15:04:05.000Z0700"
+
+// ReplayFile replays a log file written by json encoders, compressed rolled
+// files are decompressed by their extension.
+func ReplayFile(path string, l Logger, opts ReplayOptions) (ReplayResult, error) {
+	r, err := openLogFile(path)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+	defer r.Close()
+	return Replay(r, l, opts)
+}
+
+// openLogFile opens a log file, decompressing it by its extension.
+func openLogFile(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasSuffix(path, GzipCompression.Ext()):
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &decompressReader{Reader: gr, closers: []io.Closer{gr, f}}, nil
+	case strings.HasSuffix(path, ZstdCompression.Ext()):
+		zr, err := zstd.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &decompressReader{Reader: zr, closers: []io.Closer{zr.IOReadCloser(), f}}, nil
+	}
+	return f, nil
+}
+
+// decompressReader reads a decompressed file and closes the decompressor
+// along with the file.
+type decompressReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *decompressReader) Close() error {
+	var err error
+	for _, c := range r.closers {
+		err = multierr.Append(err, c.Close())
+	}
+	return err
+}
+
+// Replay reads entries written by json encoders from r and re-emits them
+// through the outputs of l, keeping their level, time, caller and fields,
+// e.g. to backfill a new log backend from archived files. Default fields of
+// l are not added again. Lines which are not json entries are skipped.
+func Replay(r io.Reader, l Logger, opts ReplayOptions) (ReplayResult, error) {
+	var result ReplayResult
+	zl, ok := l.(*logger)
+	if !ok {
+		return result, errors.New("replay is only supported by loggers created by New")
+	}
+	if opts.TimeLayout == "" {
+		opts.TimeLayout = defaultReplayTimeLayout
+	}
+
+	out := zl.outputs()
+	core := out.root.Core()
+	cfg := out.opt.encoderConfig
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		ent, fields, ok := parseReplayLine(scanner.Bytes(), cfg, opts)
+		if !ok {
+			result.Skipped++
+			continue
+		}
+		if ce := core.Check(ent, nil); ce != nil {
+			ce.Write(fields...)
+		}
+		result.Replayed++
+	}
+	return result, scanner.Err()
+}
+
+func parseReplayLine(line []byte, cfg zapcore.EncoderConfig, opts ReplayOptions) (zapcore.Entry, []zap.Field, bool) {
+	var ent zapcore.Entry
+	dec := json.NewDecoder(bytes.NewReader(line))
+	dec.UseNumber()
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil || m[fileHeaderKey] == true {
+		return ent, nil, false
+	}
+
+	ent.Level = zap.InfoLevel
+	if s, ok := m[cfg.LevelKey].(string); ok {
+		lv, _ := ParseLevel(s)
+		ent.Level = lv.unmarshalZapLevel()
+	}
+	if s, ok := m[cfg.TimeKey].(string); ok {
+		ent.Time, _ = time.Parse(opts.TimeLayout, s)
+	}
+	if opts.RewriteTime != nil {
+		ent.Time = opts.RewriteTime(ent.Time)
+	}
+	ent.Message, _ = m[cfg.MessageKey].(string)
+	ent.LoggerName, _ = m[cfg.NameKey].(string)
+	ent.Stack, _ = m[cfg.StacktraceKey].(string)
+	if s, ok := m[cfg.CallerKey].(string); ok {
+		if idx := strings.LastIndexByte(s, ':'); idx != -1 {
+			line, _ := strconv.Atoi(s[idx+1:])
+			ent.Caller = zapcore.EntryCaller{Defined: true, File: s[:idx], Line: line}
+		}
+	}
+
+	fields := make([]zap.Field, 0, len(m))
+	for k, v := range m {
+		switch k {
+		case cfg.LevelKey, cfg.TimeKey, cfg.MessageKey, cfg.NameKey, cfg.StacktraceKey, cfg.CallerKey:
+			continue
+		}
+		fields = append(fields, zap.Any(k, replayValue(v)))
+	}
+	return ent, fields, true
+}
+
+// replayValue converts json numbers back to numbers.
+func replayValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = replayValue(v[k])
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = replayValue(v[i])
+		}
+	}
+	return v
+}
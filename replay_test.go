@@ -0,0 +1,30 @@
+package logger
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplay(t *testing.T) {
+	log, logs := newObservedLogger(WithFields(map[string]interface{}{"app_id": "mt"}))
+	input := `{"level":"warn","ts":"2024-03-05T14:04:05.000+0800","caller":"module/default_test.go:120","msg":"hello there","age":22,"tags":["a"]}
+not json
+`
+	shift := 24 * time.Hour
+	result, err := Replay(strings.NewReader(input), log, ReplayOptions{
+		RewriteTime: func(t time.Time) time.Time { return t.Add(shift) },
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, ReplayResult{Replayed: 1, Skipped: 1}, result)
+
+	entries := logs.All()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, WarnLevel, int(levelFromZap(entries[0].Level)))
+	assert.Equal(t, msg, entries[0].Message)
+	assert.Equal(t, "module/default_test.go:120", entries[0].Caller.String())
+	assert.Equal(t, time.Date(2024, 3, 6, 6, 4, 5, 0, time.UTC), entries[0].Time.UTC())
+	assert.Equal(t, map[string]interface{}{"age": int64(22), "tags": []interface{}{"a"}}, entries[0].ContextMap())
+}
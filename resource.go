@@ -0,0 +1,192 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Resource is the attributes of the entity producing logs, such as
+// service.name, service.version, deployment.environment, cloud.region and
+// k8s.pod.name, attached to every entry.
+type Resource map[string]string
+
+// ResourceDetector detects attributes of the resource, detectors not
+// applicable to the environment return an error or no attributes.
+type ResourceDetector func() (Resource, error)
+
+// DetectResource merges the attributes of detectors, attributes of later
+// detectors override earlier ones. Failing detectors are skipped.
+func DetectResource(detectors ...ResourceDetector) Resource {
+	res := make(Resource)
+	for _, detect := range detectors {
+		attrs, err := detect()
+		if err != nil {
+			continue
+		}
+		for k, v := range attrs {
+			if v != "" {
+				res[k] = v
+			}
+		}
+	}
+	return res
+}
+
+// fields returns the attributes as fields, sorted by key.
+func (res Resource) fields() []zap.Field {
+	keys := make([]string, 0, len(res))
+	for k := range res {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fields := make([]zap.Field, 0, len(keys))
+	for _, k := range keys {
+		fields = append(fields, zap.String(k, res[k]))
+	}
+	return fields
+}
+
+// HostDetector detects host.name.
+func HostDetector() ResourceDetector {
+	return func() (Resource, error) {
+		host, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		return Resource{"host.name": host}, nil
+	}
+}
+
+// EnvDetector detects attributes from OTEL_RESOURCE_ATTRIBUTES such as
+// "service.version=1.2,deployment.environment=prod" and OTEL_SERVICE_NAME.
+func EnvDetector() ResourceDetector {
+	return func() (Resource, error) {
+		res := make(Resource)
+		for _, pair := range strings.Split(os.Getenv("OTEL_RESOURCE_ATTRIBUTES"), ",") {
+			if k, v, ok := cutString(pair, "="); ok {
+				res[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+		}
+		if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+			res["service.name"] = name
+		}
+		return res, nil
+	}
+}
+
+// KubernetesDetector detects k8s.pod.name, k8s.namespace.name and
+// k8s.node.name from files of a downward API volume mounted at dir, named
+// after the attributes without the "k8s." prefix, such as dir/pod.name.
+// The namespace falls back to the one of the service account.
+func KubernetesDetector(dir string) ResourceDetector {
+	return func() (Resource, error) {
+		res := make(Resource)
+		for _, attr := range []string{"pod.name", "namespace.name", "node.name"} {
+			if b, err := os.ReadFile(filepath.Join(dir, attr)); err == nil {
+				res["k8s."+attr] = strings.TrimSpace(string(b))
+			}
+		}
+		if _, ok := res["k8s.namespace.name"]; !ok {
+			if b, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
+				res["k8s.namespace.name"] = strings.TrimSpace(string(b))
+			}
+		}
+		return res, nil
+	}
+}
+
+var (
+	ec2MetadataEndpoint = "http://169.254.169.254"
+	gceMetadataEndpoint = "http://metadata.google.internal"
+	metadataTimeout     = time.Second
+)
+
+// EC2Detector detects cloud.region, cloud.availability_zone and host.id from
+// the EC2 instance metadata service.
+func EC2Detector() ResourceDetector {
+	return func() (Resource, error) {
+		token, err := metadataGet(http.MethodPut, ec2MetadataEndpoint+"/latest/api/token", map[string]string{
+			"X-aws-ec2-metadata-token-ttl-seconds": "60",
+		})
+		if err != nil {
+			return nil, err
+		}
+		header := map[string]string{"X-aws-ec2-metadata-token": token}
+		res := Resource{"cloud.provider": "aws"}
+		for attr, path := range map[string]string{
+			"cloud.region":            "/latest/meta-data/placement/region",
+			"cloud.availability_zone": "/latest/meta-data/placement/availability-zone",
+			"host.id":                 "/latest/meta-data/instance-id",
+		} {
+			if v, err := metadataGet(http.MethodGet, ec2MetadataEndpoint+path, header); err == nil {
+				res[attr] = v
+			}
+		}
+		return res, nil
+	}
+}
+
+// GCEDetector detects cloud.availability_zone, cloud.region and host.id
+// from the GCE metadata server.
+func GCEDetector() ResourceDetector {
+	return func() (Resource, error) {
+		header := map[string]string{"Metadata-Flavor": "Google"}
+		zone, err := metadataGet(http.MethodGet, gceMetadataEndpoint+"/computeMetadata/v1/instance/zone", header)
+		if err != nil {
+			return nil, err
+		}
+		// projects/123/zones/us-central1-a
+		zone = zone[strings.LastIndexByte(zone, '/')+1:]
+		res := Resource{"cloud.provider": "gcp", "cloud.availability_zone": zone}
+		if i := strings.LastIndexByte(zone, '-'); i > 0 {
+			res["cloud.region"] = zone[:i]
+		}
+		if id, err := metadataGet(http.MethodGet, gceMetadataEndpoint+"/computeMetadata/v1/instance/id", header); err == nil {
+			res["host.id"] = id
+		}
+		return res, nil
+	}
+}
+
+func metadataGet(method, url string, header map[string]string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), metadataTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata %s: unexpected status %s", url, resp.Status)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// cutString is strings.Cut, which is not available in go1.17.
+func cutString(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectResource(t *testing.T) {
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "service.version=1.2, deployment.environment=prod")
+	t.Setenv("OTEL_SERVICE_NAME", "mt")
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "pod.name"), []byte("mt-7d9f\n"), 0666))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch req.URL.Path {
+		case "/computeMetadata/v1/instance/zone":
+			w.Write([]byte("projects/123/zones/us-central1-a"))
+		case "/computeMetadata/v1/instance/id":
+			w.Write([]byte("42"))
+		}
+	}))
+	defer srv.Close()
+	endpoint := gceMetadataEndpoint
+	gceMetadataEndpoint = srv.URL
+	defer func() { gceMetadataEndpoint = endpoint }()
+
+	res := DetectResource(EnvDetector(), KubernetesDetector(dir), GCEDetector(), func() (Resource, error) {
+		return Resource{"service.name": "override"}, nil
+	})
+	assert.Equal(t, "override", res["service.name"])
+	assert.Equal(t, "1.2", res["service.version"])
+	assert.Equal(t, "prod", res["deployment.environment"])
+	assert.Equal(t, "mt-7d9f", res["k8s.pod.name"])
+	assert.Equal(t, "us-central1", res["cloud.region"])
+	assert.Equal(t, "42", res["host.id"])
+
+	log := New(WithConsole(false), WithResource(EnvDetector())).(*logger)
+	var keys []string
+	for _, f := range log.outputs().fields {
+		keys = append(keys, f.Key)
+	}
+	assert.Equal(t, []string{"deployment.environment", "service.name", "service.version"}, keys)
+}
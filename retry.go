@@ -0,0 +1,42 @@
+package logger
+
+// LogRetry logs an attempt of a retry loop, counting from 1, with err being
+// its result. Failed attempts before the last one are logged at debug level,
+// so production logs only get one entry per loop: a warning when it
+// succeeded after retrying, or an error when all attempts failed.
+func LogRetry(attempt, maxAttempts int, err error) {
+	logRetry(DefaultLogger.WithCallDepth(2), attempt, maxAttempts, err)
+}
+
+// LogErrGroup logs one entry summarizing the results of a group of tasks,
+// such as the errors collected from an errgroup, at error level if any
+// task failed.
+func LogErrGroup(results []error) {
+	logErrGroup(DefaultLogger.WithCallDepth(2), results)
+}
+
+func logRetry(l Logger, attempt, maxAttempts int, err error) {
+	switch {
+	case err == nil && attempt <= 1:
+	case err == nil:
+		l.Warnw("retry succeeded", "attempts", attempt, "max_attempts", maxAttempts)
+	case attempt >= maxAttempts:
+		l.Errorw("retry exhausted", "attempts", attempt, "max_attempts", maxAttempts, "error", err)
+	default:
+		l.Debugw("retry attempt failed", "attempt", attempt, "max_attempts", maxAttempts, "error", err)
+	}
+}
+
+func logErrGroup(l Logger, results []error) {
+	var errs []string
+	for _, err := range results {
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) == 0 {
+		l.Infow("group succeeded", "total", len(results))
+		return
+	}
+	l.Errorw("group failed", "total", len(results), "failed", len(errs), "errors", errs)
+}
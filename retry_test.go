@@ -0,0 +1,46 @@
+package logger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+func TestLogRetry(t *testing.T) {
+	log, logs := newObservedLogger()
+	err := errors.New("unavailable")
+
+	logRetry(log, 1, 3, nil)
+	assert.Zero(t, logs.Len())
+
+	logRetry(log, 1, 3, err)
+	assert.Zero(t, logs.FilterLevelExact(zap.WarnLevel).Len()+logs.FilterLevelExact(zap.ErrorLevel).Len())
+	logs.TakeAll()
+
+	logRetry(log, 2, 3, nil)
+	entries := logs.TakeAll()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, zap.WarnLevel, entries[0].Level)
+	assert.Equal(t, map[string]interface{}{"attempts": int64(2), "max_attempts": int64(3)}, entries[0].ContextMap())
+
+	logRetry(log, 3, 3, err)
+	entries = logs.TakeAll()
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "retry exhausted", entries[0].Message)
+	assert.Equal(t, "unavailable", entries[0].ContextMap()["error"])
+}
+
+func TestLogErrGroup(t *testing.T) {
+	log, logs := newObservedLogger()
+
+	logErrGroup(log, []error{nil, nil})
+	logErrGroup(log, []error{nil, errors.New("timeout")})
+	entries := logs.TakeAll()
+	assert.Len(t, entries, 2)
+	assert.Equal(t, zap.InfoLevel, entries[0].Level)
+	assert.Equal(t, zap.ErrorLevel, entries[1].Level)
+	assert.Equal(t, int64(1), entries[1].ContextMap()["failed"])
+	assert.Equal(t, []interface{}{"timeout"}, entries[1].ContextMap()["errors"])
+}
@@ -2,28 +2,47 @@ package logger
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
 )
 
-var bpool = newBufferPool(500)
+var bpool = newBufferPool(defaultBufferPoolSize)
 
 type bufferPool struct {
 	p     sync.Pool
 	count int64
 	size  int
+	free  chan struct{}
+}
+
+// poolBuffer is a buffer taken from pool, it's put back into the same pool
+// even if the pool of the rolling file changed meanwhile.
+type poolBuffer struct {
+	bytes.Buffer
+	pool *bufferPool
+}
+
+func (b *poolBuffer) recycle() {
+	b.pool.put(b)
 }
 
 func newBufferPool(size int) *bufferPool {
-	p := &bufferPool{}
+	p := &bufferPool{free: make(chan struct{}, 1)}
 	p.p.New = func() interface{} {
-		return bytes.NewBuffer(nil)
+		return &poolBuffer{pool: p}
 	}
 
 	p.size = size
@@ -31,22 +50,26 @@ func newBufferPool(size int) *bufferPool {
 	return p
 }
 
-func putBuffer(b *bytes.Buffer) {
-	bpool.p.Put(b)
-	atomic.AddInt64(&bpool.count, -1)
+func (p *bufferPool) put(b *poolBuffer) {
+	p.p.Put(b)
+	atomic.AddInt64(&p.count, -1)
+	select {
+	case p.free <- struct{}{}:
+	default:
+	}
 
 	return
 }
 
-func getBuffer() *bytes.Buffer {
+func (p *bufferPool) get() *poolBuffer {
 	for {
-		c := atomic.LoadInt64(&bpool.count)
-		if c > int64(bpool.size) {
+		c := atomic.LoadInt64(&p.count)
+		if c > int64(p.size) {
 			return nil
 		}
 
-		if atomic.CompareAndSwapInt64(&bpool.count, c, c+1) {
-			b := bpool.p.Get().(*bytes.Buffer)
+		if atomic.CompareAndSwapInt64(&p.count, c, c+1) {
+			b := p.p.Get().(*poolBuffer)
 			b.Reset()
 
 			return b
@@ -54,38 +77,189 @@ func getBuffer() *bytes.Buffer {
 	}
 }
 
+// wait blocks until a buffer is available or exit is closed.
+func (p *bufferPool) wait(exit chan struct{}) *poolBuffer {
+	t := time.NewTimer(0)
+	defer t.Stop()
+	for {
+		if b := p.get(); b != nil {
+			return b
+		}
+
+		// free only wakes up one waiter, poll in case of missed signals
+		t.Reset(10 * time.Millisecond)
+		select {
+		case <-p.free:
+			if !t.Stop() {
+				<-t.C
+			}
+		case <-t.C:
+		case <-exit:
+			return nil
+		}
+	}
+}
+
 /* }}} */
 
+// SyncPolicy : When flushed data is fsynced to disk
+type SyncPolicy int
+
+const (
+	// SyncOnFlush fsyncs whenever buffered data is flushed by Sync, Reopen or Close.
+	SyncOnFlush SyncPolicy = iota
+	// SyncPeriodically fsyncs at most once per interval, also without explicit flushes.
+	SyncPeriodically
+	// SyncNever never fsyncs and relies on the OS to write back data.
+	SyncNever
+)
+
+// OverflowPolicy : What Write does when the buffer pool is exhausted
+type OverflowPolicy int
+
+const (
+	// OverflowDrop drops the entry, counts it and returns ErrBuffer.
+	OverflowDrop OverflowPolicy = iota
+	// OverflowBlock blocks the writer until a buffer is free.
+	OverflowBlock
+	// OverflowDirect writes the entry synchronously to the file.
+	OverflowDirect
+	// OverflowSpill appends the entry to an on-disk queue next to the files,
+	// which is drained asynchronously. Full buffers are spilled as well when
+	// the flusher lags behind instead of blocking the writer.
+	OverflowSpill
+)
+
+type directWrite struct {
+	b    []byte
+	sync bool
+	done chan error
+}
+
 // RollingFile : Defination of rolling
 type RollingFile struct {
 	mu sync.Mutex
 
 	closed    bool
+	running   bool
+	users     int32
+	lazyIdle  time.Duration
 	exit      chan struct{}
+	done      chan struct{}
+	closeErr  error
 	syncFlush chan struct{}
+	direct    chan directWrite
+	reopen    chan chan error
+	flush     chan chan error
 
-	file       *os.File
-	current    *bytes.Buffer
-	fullBuffer chan *bytes.Buffer
+	intervalChanged chan struct{}
+	lazyChanged     chan struct{}
+	dropped         int64
+
+	file           *os.File
+	current        *poolBuffer
+	fullBuffer     chan *poolBuffer
+	pool           atomic.Value // *bufferPool
+	flushThreshold int
+	directMode     bool
+	directSync     bool
 
 	basePath string
 	filePath string
 	fileFrag string
 	fileExt  string
+	fileMode os.FileMode
+	dirMode  os.FileMode
 
 	rollMutex sync.RWMutex
 	rolling   RollingFormat
+	rollFunc  RollingFunc
+	maxSize   int64
+	location  *time.Location
+	clock     zapcore.Clock
+
+	flushInterval time.Duration
+	syncPolicy    SyncPolicy
+	syncInterval  time.Duration
+	watchInterval time.Duration
+
+	compression      Compression
+	compressionLevel int
+	compressionDelay time.Duration
+	symlink          bool
+	flatLayout       bool
+	filenameTemplate string
+	preallocSize     int64
+	header           map[string]interface{}
+	manifest         bool
+	maxTotalSize     int64
+	rateLimit        int64
+	overflow         OverflowPolicy
+	onError          func(error)
+	diskFullFallback bool
+	fileLock         bool
+	onRotated        func(path string) error
+	beforeRotate     func(path string, w io.Writer)
+	afterRotate      func(oldPath, newPath string)
+	onPressure       func(degraded bool)
+	degraded         int32
+
+	cpMutex     sync.Mutex
+	checkpoints []Checkpoint
+
+	// counters of Stats, updated atomically
+	counters rollingCounters
+
+	// guarded by statsMutex
+	statsMutex    sync.Mutex
+	lastRoll      time.Time
+	lastError     error
+	lastErrorTime time.Time
+
+	describeMutex sync.Mutex
+	describeStats RollingStats
+	describeAt    time.Time
+
+	persistMutex  sync.Mutex
+	persistErr    error
+	persistFailed int32
+
+	spillMutex   sync.Mutex
+	spillFile    *os.File
+	spilled      bool
+	spillPending int32
+
+	// owned by flushRoutine
+	closing      bool
+	lastSync     time.Time
+	lastCheck    time.Time
+	offset       int64
+	sizeIndex    int
+	stderr       io.Writer
+	diskFullAt   time.Time
+	diskFullWarn time.Time
+	rateTokens   float64
+	rateAt       time.Time
+	idleWrites   int64
+	idleAt       time.Time
 }
 
 // Errors
 var (
 	ErrClosedRollingFile = errors.New("rolling file is closed")
 	ErrBuffer            = errors.New("buffer exceeds the limit")
+	ErrManifestMismatch  = errors.New("file does not match its manifest")
 )
 
 // RollingFormat : Type hinting
 type RollingFormat string
 
+// RollingFunc : Custom rolling layout, it returns the directory relative to the
+// directory of base path and the file name without extension of the file
+// holding logs written at t, name is the base file name such as "info".
+// A new file is opened whenever the returned values change.
+type RollingFunc func(name string, t time.Time) (dir, filename string)
+
 // RollingFormats
 const (
 	MonthlyRolling  RollingFormat = "200601"
@@ -96,9 +270,17 @@ const (
 )
 
 const (
-	logPageCacheByteSize = 4096
-	logPageNumber        = 2
-	defaultFileExt       = "log"
+	logPageCacheByteSize  = 4096
+	defaultBufferPoolSize = 500
+	logPageNumber         = 2
+	defaultFileExt        = "log"
+	defaultFileMode       = 0666
+	defaultDirMode        = 0777
+
+	defaultFlushInterval = 500 * time.Millisecond
+
+	diskFullRetryInterval = 10 * time.Second
+	diskFullWarnInterval  = time.Minute
 )
 
 // SetRolling : Set rolling format
@@ -110,36 +292,441 @@ func (r *RollingFile) SetRolling(fmt RollingFormat) {
 	return
 }
 
+// SetRollingFunc : Set custom rolling layout, it takes precedence over the rolling format
+func (r *RollingFile) SetRollingFunc(fn RollingFunc) {
+	r.rollMutex.Lock()
+	r.rollFunc = fn
+	r.rollMutex.Unlock()
+}
+
+// SetMaxSize : Also roll when the active file exceeds size bytes, files within
+// the same time fragment are numbered, e.g. info_15.log, info_15.1.log
+func (r *RollingFile) SetMaxSize(size int64) {
+	r.rollMutex.Lock()
+	r.maxSize = size
+	r.rollMutex.Unlock()
+}
+
+// SetLocation : Set time zone of directory fragments and file suffixes, nil means local time
+func (r *RollingFile) SetLocation(loc *time.Location) {
+	r.rollMutex.Lock()
+	r.location = loc
+	r.rollMutex.Unlock()
+}
+
+// SetFlushInterval : Set interval buffered data is flushed at
+func (r *RollingFile) SetFlushInterval(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	r.rollMutex.Lock()
+	r.flushInterval = d
+	r.rollMutex.Unlock()
+
+	select {
+	case r.intervalChanged <- struct{}{}:
+	default:
+	}
+}
+
+// SetSyncPolicy : Set when flushed data is fsynced, interval applies to SyncPeriodically
+func (r *RollingFile) SetSyncPolicy(policy SyncPolicy, interval time.Duration) {
+	r.rollMutex.Lock()
+	r.syncPolicy = policy
+	r.syncInterval = interval
+	r.rollMutex.Unlock()
+}
+
+// SetWatchInterval : Check every interval whether the active file was deleted
+// or moved and reopen it by its path, 0 disables checking
+func (r *RollingFile) SetWatchInterval(interval time.Duration) {
+	r.rollMutex.Lock()
+	r.watchInterval = interval
+	r.rollMutex.Unlock()
+}
+
+// SetClock : Set the clock telling the time files are named after, nil means
+// the system clock
+func (r *RollingFile) SetClock(clock zapcore.Clock) {
+	r.rollMutex.Lock()
+	r.clock = clock
+	r.rollMutex.Unlock()
+}
+
+// SetLazyFlush : Stop the flush goroutine until the first write, and once
+// nothing was written for idle, the next write starts it again, 0 keeps it
+// running until Close
+func (r *RollingFile) SetLazyFlush(idle time.Duration) {
+	r.mu.Lock()
+	r.lazyIdle = idle
+	r.mu.Unlock()
+	if idle > 0 {
+		select {
+		case r.lazyChanged <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// hold starts the flush routine if it isn't running and keeps it from
+// stopping idle until release, the caller holds r.mu.
+func (r *RollingFile) hold() {
+	atomic.AddInt32(&r.users, 1)
+	if !r.running {
+		r.running = true
+		go r.flushRoutine()
+	}
+}
+
+func (r *RollingFile) release() {
+	atomic.AddInt32(&r.users, -1)
+}
+
+// stopIdle reports whether the flush routine stops, it does once lazy flush
+// is enabled, no write arrived for the idle period and nothing is left to
+// flush.
+func (r *RollingFile) stopIdle() bool {
+	if writes := atomic.LoadInt64(&r.counters.Writes); writes != r.idleWrites || r.idleAt.IsZero() {
+		r.idleWrites = writes
+		r.idleAt = time.Now()
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lazyIdle <= 0 || time.Since(r.idleAt) < r.lazyIdle || !r.idle() {
+		return false
+	}
+	r.syncFile(false)
+	r.running = false
+	return true
+}
+
+// stopUnused reports whether the flush routine stops until the first write,
+// it does once lazy flush is enabled if nothing was written yet.
+func (r *RollingFile) stopUnused() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.lazyIdle <= 0 || atomic.LoadInt64(&r.counters.Writes) != 0 || !r.idle() {
+		return false
+	}
+	r.running = false
+	return true
+}
+
+// idle reports whether nothing is left to flush, the caller holds r.mu.
+func (r *RollingFile) idle() bool {
+	return atomic.LoadInt32(&r.users) == 0 && len(r.fullBuffer) == 0 &&
+		(r.current == nil || r.current.Len() == 0) && atomic.LoadInt32(&r.spillPending) == 0
+}
+
+// SetCompression : Set compression applied to rolled files, level 0 means default level
+func (r *RollingFile) SetCompression(c Compression, level int) {
+	r.rollMutex.Lock()
+	r.compression = c
+	r.compressionLevel = level
+	r.rollMutex.Unlock()
+}
+
+// SetCompressionDelay : Compress rotated files only after a grace period, in
+// which late writers such as other processes may still append to them
+func (r *RollingFile) SetCompressionDelay(d time.Duration) {
+	r.rollMutex.Lock()
+	r.compressionDelay = d
+	r.rollMutex.Unlock()
+}
+
+// SetFileExt : Set extension of log files, e.g. "jsonl" or ".txt"
+func (r *RollingFile) SetFileExt(ext string) {
+	r.rollMutex.Lock()
+	r.fileExt = strings.TrimPrefix(ext, ".")
+	r.rollMutex.Unlock()
+}
+
+// SetFileMode : Set permission bits of created log files
+func (r *RollingFile) SetFileMode(mode os.FileMode) {
+	r.rollMutex.Lock()
+	r.fileMode = mode
+	r.rollMutex.Unlock()
+}
+
+// SetDirMode : Set permission bits of created log directories
+func (r *RollingFile) SetDirMode(mode os.FileMode) {
+	r.rollMutex.Lock()
+	r.dirMode = mode
+	r.rollMutex.Unlock()
+}
+
+// SetSymlink : Maintain a symlink basePath.ext pointing at the active file, a
+// hard link on Windows when symlinks can't be created
+func (r *RollingFile) SetSymlink(enable bool) {
+	r.rollMutex.Lock()
+	r.symlink = enable
+	r.rollMutex.Unlock()
+}
+
+// SetFlatLayout : Name rolled files such as info-2006010215.log in the
+// directory of base path instead of nesting them in time directories
+func (r *RollingFile) SetFlatLayout(enable bool) {
+	r.rollMutex.Lock()
+	r.flatLayout = enable
+	r.rollMutex.Unlock()
+}
+
+// SetFilenameTemplate : Name rolled files by a template relative to the
+// directory of base path, such as "{name}-{date}-{host}.{ext}"
+func (r *RollingFile) SetFilenameTemplate(template string) {
+	r.rollMutex.Lock()
+	r.filenameTemplate = template
+	r.rollMutex.Unlock()
+}
+
+// SetPreallocate : Reserve size bytes of disk space for every new file, which
+// reduces fragmentation and surfaces a full disk early, linux only
+func (r *RollingFile) SetPreallocate(size int64) {
+	r.rollMutex.Lock()
+	r.preallocSize = size
+	r.rollMutex.Unlock()
+}
+
+// SetHeader : Write a json header line with the host name, pid and creation
+// time along with fields at the top of every new file, nil disables it
+func (r *RollingFile) SetHeader(fields map[string]interface{}) {
+	r.rollMutex.Lock()
+	r.header = fields
+	r.rollMutex.Unlock()
+}
+
+// SetManifest : Write a manifest with the name, size and sha256 of every
+// rotated file next to it, such as info_15.log.gz.manifest.json
+func (r *RollingFile) SetManifest(enable bool) {
+	r.rollMutex.Lock()
+	r.manifest = enable
+	r.rollMutex.Unlock()
+}
+
+// SetMaxTotalSize : Remove the oldest files of this rolling file in the directory
+// of base path and its sub directories after rotation while they take more than
+// size bytes, other files in the directories are neither counted nor removed
+func (r *RollingFile) SetMaxTotalSize(size int64) {
+	r.rollMutex.Lock()
+	r.maxTotalSize = size
+	r.rollMutex.Unlock()
+}
+
+// SetWriteRateLimit : Throttle writes to disk to bytesPerSec, with bursts of
+// up to a second of writes, 0 means no limit
+func (r *RollingFile) SetWriteRateLimit(bytesPerSec int64) {
+	r.rollMutex.Lock()
+	r.rateLimit = bytesPerSec
+	r.rollMutex.Unlock()
+}
+
+// SetBufferPoolSize : Use a dedicated buffer pool holding at most size buffers,
+// it should be called before writing
+func (r *RollingFile) SetBufferPoolSize(size int) {
+	r.mu.Lock()
+	if r.current != nil {
+		r.current.recycle()
+		r.current = nil
+	}
+	r.pool.Store(newBufferPool(size))
+	r.mu.Unlock()
+}
+
+// bufferPool returns the pool buffers are taken from, buffers in flight
+// return to the pool they were taken from.
+func (r *RollingFile) bufferPool() *bufferPool {
+	return r.pool.Load().(*bufferPool)
+}
+
+// SetDirectWrite : Write every entry straight to the file when Write is
+// called instead of buffering it, and fsync it as well if sync is true, for
+// strict write-through semantics such as audit logs
+func (r *RollingFile) SetDirectWrite(enable, sync bool) {
+	r.mu.Lock()
+	r.directMode = enable
+	r.directSync = sync
+	r.mu.Unlock()
+	// flush data buffered before
+	r.Sync()
+}
+
+// SetFlushThreshold : Set bytes buffered before a buffer is handed to the flusher
+func (r *RollingFile) SetFlushThreshold(size int) {
+	r.mu.Lock()
+	r.flushThreshold = size
+	r.mu.Unlock()
+}
+
+// SetOverflowPolicy : Set what Write does when the buffer pool is exhausted
+func (r *RollingFile) SetOverflowPolicy(policy OverflowPolicy) {
+	r.rollMutex.Lock()
+	r.overflow = policy
+	r.rollMutex.Unlock()
+}
+
+// Dropped : Number of writes dropped because the buffer pool was exhausted
+func (r *RollingFile) Dropped() int64 {
+	return atomic.LoadInt64(&r.dropped)
+}
+
+// SetErrorHandler : Set callback invoked when writing, syncing or compressing fails
+func (r *RollingFile) SetErrorHandler(fn func(error)) {
+	r.rollMutex.Lock()
+	r.onError = fn
+	r.rollMutex.Unlock()
+}
+
+// reportError is handleError for the flush routine, errors of the final
+// flush are also returned by Close.
+func (r *RollingFile) reportError(err error) {
+	if err == nil {
+		return
+	}
+	if r.closing {
+		r.closeErr = multierr.Append(r.closeErr, err)
+	}
+	r.handleError(err)
+}
+
+func (r *RollingFile) handleError(err error) {
+	if err == nil {
+		return
+	}
+	r.recordError(err)
+	r.rollMutex.RLock()
+	fn := r.onError
+	r.rollMutex.RUnlock()
+	if fn != nil {
+		fn(err)
+	}
+}
+
+// SetFileLock : Hold an advisory flock while writing, so processes sharing
+// the same files don't interleave their buffers
+func (r *RollingFile) SetFileLock(enable bool) {
+	r.rollMutex.Lock()
+	r.fileLock = enable
+	r.rollMutex.Unlock()
+}
+
+// SetOnRotated : Call fn with the path of every rotated file once it's
+// closed and compressed, errors are passed to the error handler
+func (r *RollingFile) SetOnRotated(fn func(path string) error) {
+	r.rollMutex.Lock()
+	r.onRotated = fn
+	r.rollMutex.Unlock()
+}
+
+// SetOnBeforeRotate : Call fn with the path of the active file and a writer
+// appending to it right before it's closed by rotation, e.g. to write trailer
+// records. fn runs on the flush goroutine and blocks writes
+func (r *RollingFile) SetOnBeforeRotate(fn func(path string, w io.Writer)) {
+	r.rollMutex.Lock()
+	r.beforeRotate = fn
+	r.rollMutex.Unlock()
+}
+
+// SetOnAfterRotate : Call fn with the paths of the rotated and the new file
+// right after the new file is opened. fn runs on the flush goroutine and
+// blocks writes
+func (r *RollingFile) SetOnAfterRotate(fn func(oldPath, newPath string)) {
+	r.rollMutex.Lock()
+	r.afterRotate = fn
+	r.rollMutex.Unlock()
+}
+
+// SetDiskFullFallback : Write logs to stderr while the disk is full, instead of discarding them
+func (r *RollingFile) SetDiskFullFallback(enable bool) {
+	r.rollMutex.Lock()
+	r.diskFullFallback = enable
+	r.rollMutex.Unlock()
+}
+
 /* {{{ [roll] */
 func (r *RollingFile) roll() error {
 	r.rollMutex.RLock()
-	roll := r.rolling
+	roll, rollFunc := r.rolling, r.rollFunc
+	compressionDelay := r.compressionDelay
+	rot := rotation{
+		compression:  r.compression,
+		level:        r.compressionLevel,
+		manifest:     r.manifest,
+		fileMode:     r.fileMode,
+		maxTotalSize: r.maxTotalSize,
+		onRotated:    r.onRotated,
+	}
+	ext, fileMode, dirMode := r.fileExt, r.fileMode, r.dirMode
+	symlink, maxSize, flat := r.symlink, r.maxSize, r.flatLayout
+	template, preallocSize, header := r.filenameTemplate, r.preallocSize, r.header
+	beforeRotate, afterRotate := r.beforeRotate, r.afterRotate
 	now := time.Now()
+	if r.clock != nil {
+		now = r.clock.Now()
+	}
+	if r.location != nil {
+		now = now.In(r.location)
+	}
 	r.rollMutex.RUnlock()
-	suffix := now.Format(string(roll))
+	dir, filename := filepath.Split(r.basePath)
+	var suffix, fDir, fName string
+	if rollFunc != nil {
+		fDir, fName = rollFunc(filename, now)
+		suffix = filepath.Join(fDir, fName)
+	} else {
+		suffix = now.Format(string(roll))
+	}
+	var rotatedPath string
 	if r.file != nil {
 		if suffix == r.fileFrag {
-			return nil
+			if maxSize <= 0 || r.offset < maxSize {
+				return nil
+			}
+			// size exceeded within the same time fragment, continue with the next number
+			r.sizeIndex++
 		}
 
-		r.file.Close()
-		r.file = nil
+		if beforeRotate != nil {
+			beforeRotate(r.filePath, fileWriter{r})
+		}
+		rotatedPath = r.filePath
+		r.reportError(r.closeFile())
+		r.recordRoll()
+		if rot.pending() {
+			filePath := r.filePath
+			if compressionDelay > 0 {
+				time.AfterFunc(compressionDelay, func() {
+					r.rotated(filePath, rot)
+				})
+			} else {
+				go r.rotated(filePath, rot)
+			}
+		}
 	}
 
+	if suffix != r.fileFrag {
+		r.sizeIndex = 0
+	}
 	r.fileFrag = suffix
-	dir, filename := filepath.Split(r.basePath)
 	if dir != "" && dir != "." {
-		if err := os.MkdirAll(dir, 0777); err != nil {
+		if err := os.MkdirAll(dir, dirMode); err != nil {
 			return err
 		}
 	}
 
-	if r.fileFrag == "" {
-		r.filePath = filepath.Join(dir, filename+r.fileExt)
+	if rollFunc != nil {
+		r.filePath = filepath.Join(dir, fDir, fName+"."+ext)
+	} else if r.fileFrag == "" {
+		r.filePath = filepath.Join(dir, filename+"."+ext)
+	} else if template != "" {
+		r.filePath = filepath.Join(dir, expandFilename(template, filename, r.fileFrag, ext))
+	} else if flat {
+		r.filePath = filepath.Join(dir, filename+"-"+r.fileFrag+"."+ext)
 	} else {
 		tDir := dir
 		tFilename := dir
-		switch r.rolling {
+		switch roll {
 		case MonthlyRolling:
 			tDir = fmt.Sprintf(
 				"%s/%04d",
@@ -150,7 +737,7 @@ func (r *RollingFile) roll() error {
 				"%s_%02d.%s",
 				filename,
 				now.Month(),
-				r.fileExt,
+				ext,
 			)
 		case DailyRolling:
 			tDir = fmt.Sprintf(
@@ -163,7 +750,7 @@ func (r *RollingFile) roll() error {
 				"%s_%02d.%s",
 				filename,
 				now.Day(),
-				r.fileExt,
+				ext,
 			)
 		case HourlyRolling:
 			tDir = fmt.Sprintf(
@@ -177,7 +764,7 @@ func (r *RollingFile) roll() error {
 				"%s_%02d.%s",
 				filename,
 				now.Hour(),
-				r.fileExt,
+				ext,
 			)
 		case MinutelyRolling:
 			tDir = fmt.Sprintf(
@@ -192,7 +779,7 @@ func (r *RollingFile) roll() error {
 				"%s_%02d.%s",
 				filename,
 				now.Minute(),
-				r.fileExt,
+				ext,
 			)
 		case SecondlyRolling:
 			tDir = fmt.Sprintf(
@@ -208,7 +795,7 @@ func (r *RollingFile) roll() error {
 				"%s_%02d.%s",
 				filename,
 				now.Second(),
-				r.fileExt,
+				ext,
 			)
 		}
 
@@ -218,35 +805,126 @@ func (r *RollingFile) roll() error {
 	// Make sub dir again
 	dir, filename = filepath.Split(r.filePath)
 	if dir != "" && dir != "." {
-		if err := os.MkdirAll(dir, 0777); err != nil {
+		if err := os.MkdirAll(dir, dirMode); err != nil {
 			return err
 		}
 	}
 
-	f, err := os.OpenFile(r.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
-		return err
-	}
+	// skip numbered files already full, e.g. after a restart
+	fragPath := r.filePath
+	for {
+		r.filePath = sizedFilePath(fragPath, ext, r.sizeIndex)
+		f, err := os.OpenFile(r.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, platformFileMode(fileMode))
+		if err != nil {
+			return err
+		}
 
-	r.file = f
+		r.offset = 0
+		if info, err := f.Stat(); err == nil {
+			r.offset = info.Size()
+		}
+		if maxSize > 0 && r.offset >= maxSize {
+			f.Close()
+			r.sizeIndex++
+			continue
+		}
+
+		if preallocSize > 0 && r.offset == 0 {
+			r.reportError(preallocate(f, preallocSize))
+		}
+		r.file = f
+		break
+	}
+	if header != nil && r.offset == 0 {
+		r.reportError(r.writeHeader(header, now))
+	}
+	r.recordCheckpoint(false)
+	if symlink {
+		r.createSymLink(r.filePath, r.basePath+"."+ext)
+	}
+	if afterRotate != nil && rotatedPath != "" {
+		afterRotate(rotatedPath, r.filePath)
+	}
 
 	return nil
 }
 
 /* }}} */
 
+// rotation is the work done in the background on a rotated file.
+type rotation struct {
+	compression  Compression
+	level        int
+	manifest     bool
+	fileMode     os.FileMode
+	maxTotalSize int64
+	onRotated    func(path string) error
+}
+
+func (rot rotation) pending() bool {
+	return rot.compression != NoCompression || rot.manifest || rot.maxTotalSize > 0 || rot.onRotated != nil
+}
+
+// rotated compresses a rotated file, writes its manifest, hands it to the
+// rotated hook and evicts the oldest files beyond the total size. The
+// uncompressed file is handed over if compressing fails.
+func (r *RollingFile) rotated(path string, rot rotation) {
+	if err := compressFile(path, rot.compression, rot.level); err != nil {
+		r.handleError(err)
+	} else {
+		path += rot.compression.Ext()
+	}
+	if rot.manifest {
+		r.handleError(writeManifest(path, rot.fileMode))
+	}
+	if rot.onRotated != nil {
+		r.handleError(rot.onRotated(path))
+	}
+	if rot.maxTotalSize > 0 {
+		r.handleError(evictOldest(filepath.Dir(r.basePath), r.ownsFile, r.fileDepth(), rot.maxTotalSize))
+	}
+}
+
+// expandFilename replaces the placeholders of a filename template: {name} is
+// the base file name, {date} the time formatted by the rolling format, {ext}
+// the file extension, {host} the host name and {pid} the process id.
+func expandFilename(template, name, date, ext string) string {
+	host, _ := os.Hostname()
+	return strings.NewReplacer(
+		"{name}", name,
+		"{date}", date,
+		"{ext}", ext,
+		"{host}", host,
+		"{pid}", strconv.Itoa(os.Getpid()),
+	).Replace(template)
+}
+
+// sizedFilePath inserts the size index before the extension, e.g. info_15.2.log.
+func sizedFilePath(path, ext string, index int) string {
+	if index == 0 {
+		return path
+	}
+	return strings.TrimSuffix(path, "."+ext) + "." + strconv.Itoa(index) + "." + ext
+}
+
 /* {{{ [createSymLink] */
 func (r *RollingFile) createSymLink(real, sym string) {
+	// the active file itself has the name of the link, e.g. with an empty
+	// rolling fragment
+	if filepath.Clean(real) == filepath.Clean(sym) {
+		return
+	}
 	if _, err := os.Lstat(sym); err == nil {
 		os.Remove(sym)
 	}
 
-	os.Symlink(real, sym)
+	linkLatest(real, sym)
 }
 
 /* }}} */
 
-// Close syncer file
+// Close flushes buffered data and closes the file, it blocks until the final
+// flush completes and returns its errors
 func (r *RollingFile) Close() error {
 	r.mu.Lock()
 	if r.closed {
@@ -255,10 +933,35 @@ func (r *RollingFile) Close() error {
 	}
 
 	r.closed = true
+	// the flush routine does the final flush, even if it stopped idle
+	r.hold()
 	r.mu.Unlock()
 	close(r.exit)
+	unregisterRollingFile(r)
+	<-r.done
 
-	return nil
+	return r.closeErr
+}
+
+// Reopen : Flush buffered data and reopen the file, used after an external
+// tool such as logrotate renamed it
+func (r *RollingFile) Reopen() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrClosedRollingFile
+	}
+	r.hold()
+	r.mu.Unlock()
+	defer r.release()
+
+	done := make(chan error, 1)
+	select {
+	case r.reopen <- done:
+	case <-r.exit:
+		return ErrClosedRollingFile
+	}
+	return <-done
 }
 
 func (r *RollingFile) Write(b []byte) (n int, err error) {
@@ -267,28 +970,108 @@ func (r *RollingFile) Write(b []byte) (n int, err error) {
 		r.mu.Unlock()
 		return 0, ErrClosedRollingFile
 	}
+	r.hold()
+	defer r.release()
+
+	if r.directMode {
+		sync := r.directSync
+		r.mu.Unlock()
+		return r.writeThrough(b, sync)
+	}
 
 	if r.current == nil {
-		r.current = getBuffer()
+		r.current = r.bufferPool().get()
 		if r.current == nil {
 			r.mu.Unlock()
-			return 0, ErrBuffer
+			return r.writeOverflow(b)
 		}
 	}
 
 	n, err = r.current.Write(b)
-	if r.current.Len() > logPageCacheByteSize {
+	if r.current.Len() > r.flushThreshold {
 		buf := r.current
 		r.current = nil
 		r.mu.Unlock()
-		r.fullBuffer <- buf
+		r.rollMutex.RLock()
+		spill := r.overflow == OverflowSpill
+		r.rollMutex.RUnlock()
+		if spill {
+			r.handOver(buf)
+		} else {
+			select {
+			case r.fullBuffer <- buf:
+			case <-r.exit:
+				// closed concurrently, the flush routine is gone
+				return 0, ErrClosedRollingFile
+			}
+		}
+		r.checkPressure()
 	} else {
 		r.mu.Unlock()
 	}
+	atomic.AddInt64(&r.counters.Writes, 1)
+	atomic.AddInt64(&r.counters.BytesAccepted, int64(len(b)))
 
+	// entries are buffered anyway, in case persisting recovers
+	if err == nil {
+		err = r.persistError()
+	}
 	return
 }
 
+// writeThrough has the flush routine write b straight to the file, fsyncing
+// it if sync is true, and waits for the result.
+func (r *RollingFile) writeThrough(b []byte, sync bool) (int, error) {
+	req := directWrite{b: b, sync: sync, done: make(chan error, 1)}
+	select {
+	case r.direct <- req:
+	case <-r.exit:
+		return 0, ErrClosedRollingFile
+	}
+	if err := <-req.done; err != nil {
+		return 0, err
+	}
+	atomic.AddInt64(&r.counters.Writes, 1)
+	atomic.AddInt64(&r.counters.BytesAccepted, int64(len(b)))
+	return len(b), nil
+}
+
+// writeOverflow handles b according to the overflow policy when no buffer is available.
+func (r *RollingFile) writeOverflow(b []byte) (int, error) {
+	r.checkPressure()
+	r.rollMutex.RLock()
+	policy := r.overflow
+	r.rollMutex.RUnlock()
+
+	switch policy {
+	case OverflowBlock:
+		buf := r.bufferPool().wait(r.exit)
+		if buf == nil {
+			return 0, ErrClosedRollingFile
+		}
+		r.mu.Lock()
+		if r.current == nil {
+			r.current = buf
+		} else {
+			buf.recycle()
+		}
+		r.mu.Unlock()
+		return r.Write(b)
+	case OverflowDirect:
+		return r.writeThrough(b, false)
+	case OverflowSpill:
+		r.spillMutex.Lock()
+		r.spill(b)
+		r.spillMutex.Unlock()
+		atomic.AddInt64(&r.counters.Writes, 1)
+		atomic.AddInt64(&r.counters.BytesAccepted, int64(len(b)))
+		return len(b), nil
+	default:
+		atomic.AddInt64(&r.dropped, 1)
+		return 0, ErrBuffer
+	}
+}
+
 // Sync buffered data to writer
 func (r *RollingFile) Sync() error {
 	r.mu.Lock()
@@ -296,70 +1079,366 @@ func (r *RollingFile) Sync() error {
 		r.mu.Unlock()
 		return ErrClosedRollingFile
 	}
-
+	r.hold()
 	r.mu.Unlock()
+	defer r.release()
+
 	r.syncFlush <- struct{}{}
 	<-r.syncFlush
 
-	return nil
+	return r.persistError()
+}
+
+// Flush buffered data to writer like Sync, but returns ctx.Err() once ctx is
+// done, leaving the data to be flushed in the background, so shutdown doesn't
+// hang behind a slow disk.
+func (r *RollingFile) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrClosedRollingFile
+	}
+	r.hold()
+	r.mu.Unlock()
+	defer r.release()
+
+	done := make(chan error, 1)
+	select {
+	case r.flush <- done:
+	case <-r.exit:
+		return ErrClosedRollingFile
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// setPersistError records the result of opening the file to persist buffered
+// data, following Write and Sync calls return the error until it succeeds.
+func (r *RollingFile) setPersistError(err error) {
+	if err == nil && atomic.LoadInt32(&r.persistFailed) == 0 {
+		return
+	}
+	r.persistMutex.Lock()
+	r.persistErr = err
+	if err != nil {
+		atomic.StoreInt32(&r.persistFailed, 1)
+	} else {
+		atomic.StoreInt32(&r.persistFailed, 0)
+	}
+	r.persistMutex.Unlock()
+}
+
+// persistError returns the error of the last failed attempt to open the file.
+func (r *RollingFile) persistError() error {
+	if atomic.LoadInt32(&r.persistFailed) == 0 {
+		return nil
+	}
+	r.persistMutex.Lock()
+	defer r.persistMutex.Unlock()
+	return r.persistErr
 }
 
 /* {{{ [writeBuffer] */
-func (r *RollingFile) writeBuffer(buff *bytes.Buffer) {
-	if buff != nil && buff.Len() > 0 {
-		if err := r.roll(); err != nil {
-		} else {
-			buff.WriteTo(r.file)
+func (r *RollingFile) writeBuffer(buff *poolBuffer) {
+	if buff == nil || buff.Len() == 0 {
+		return
+	}
+
+	r.rollMutex.RLock()
+	fallback := r.diskFullFallback
+	r.rollMutex.RUnlock()
+
+	// console only until the retry interval elapsed
+	if fallback && !r.diskFullAt.IsZero() && time.Since(r.diskFullAt) < diskFullRetryInterval {
+		buff.WriteTo(r.stderr)
+		return
+	}
+
+	if err := r.roll(); err != nil {
+		r.setPersistError(err)
+		r.reportError(err)
+		return
+	}
+
+	r.setPersistError(nil)
+
+	b := buff.Bytes()
+	n, err := r.writeFile(b)
+	if err == nil {
+		r.diskFullAt = time.Time{}
+		return
+	}
+
+	r.reportError(err)
+	if errors.Is(err, syscall.ENOSPC) {
+		now := time.Now()
+		if now.Sub(r.diskFullWarn) >= diskFullWarnInterval {
+			r.diskFullWarn = now
+			fmt.Fprintf(r.stderr, "logger: no space left on device writing %s, fallback to stderr: %t\n", r.filePath, fallback)
+		}
+		if fallback {
+			r.diskFullAt = now
+			r.stderr.Write(b[n:])
 		}
 	}
 }
 
 /* }}} */
 
+// writeDirect writes b straight to the file, bypassing the buffers.
+func (r *RollingFile) writeDirect(b []byte) error {
+	if err := r.roll(); err != nil {
+		return err
+	}
+	_, err := r.writeFile(b)
+	return err
+}
+
+// writeFile writes b to the file in a single write, under the file lock if
+// enabled. The file is opened with O_APPEND, so each write lands at the end
+// of the file as a whole even if other processes append to it.
+func (r *RollingFile) writeFile(b []byte) (int, error) {
+	r.rollMutex.RLock()
+	locking, rate := r.fileLock, r.rateLimit
+	r.rollMutex.RUnlock()
+
+	if rate > 0 {
+		r.throttle(len(b), rate)
+	}
+
+	if locking {
+		if err := lockFile(r.file); err != nil {
+			return 0, err
+		}
+		defer unlockFile(r.file)
+	}
+
+	n, err := r.file.Write(b)
+	r.offset += int64(n)
+	atomic.AddInt64(&r.counters.BytesWritten, int64(n))
+	atomic.AddInt64(&r.counters.FileWrites, 1)
+	if locking {
+		// other processes append too, keep the size used to roll accurate
+		if fi, serr := r.file.Stat(); serr == nil {
+			r.offset = fi.Size()
+		}
+	}
+	return n, err
+}
+
+// throttle blocks until n bytes may be written at rate bytes per second,
+// bursts of up to a second of writes pass at once.
+func (r *RollingFile) throttle(n int, rate int64) {
+	now := time.Now()
+	if r.rateAt.IsZero() {
+		r.rateTokens = float64(rate)
+	} else {
+		r.rateTokens += now.Sub(r.rateAt).Seconds() * float64(rate)
+	}
+	if r.rateTokens > float64(rate) {
+		r.rateTokens = float64(rate)
+	}
+	r.rateAt = now
+
+	r.rateTokens -= float64(n)
+	if r.rateTokens < 0 {
+		wait := time.Duration(-r.rateTokens / float64(rate) * float64(time.Second))
+		time.Sleep(wait)
+		r.rateTokens = 0
+		r.rateAt = now.Add(wait)
+	}
+}
+
+// fileWriter writes straight to the active file of a rolling file.
+type fileWriter struct {
+	r *RollingFile
+}
+
+func (w fileWriter) Write(b []byte) (int, error) {
+	return w.r.writeFile(b)
+}
+
+// syncFile fsyncs the active file according to the sync policy, tick reports
+// whether it's called by the ticker rather than a flush.
+func (r *RollingFile) syncFile(tick bool) {
+	if r.file == nil {
+		return
+	}
+
+	r.rollMutex.RLock()
+	policy, interval := r.syncPolicy, r.syncInterval
+	r.rollMutex.RUnlock()
+
+	switch policy {
+	case SyncNever:
+		if !tick {
+			r.recordCheckpoint(false)
+		}
+		return
+	case SyncPeriodically:
+		if time.Since(r.lastSync) < interval {
+			return
+		}
+	default:
+		if tick {
+			return
+		}
+	}
+
+	atomic.AddInt64(&r.counters.Syncs, 1)
+	if err := r.file.Sync(); err != nil {
+		r.reportError(err)
+		return
+	}
+	r.lastSync = time.Now()
+	r.recordCheckpoint(false)
+}
+
+// checkFile closes the active file when it was deleted or moved by someone
+// else, so the next write opens it again by its path.
+func (r *RollingFile) checkFile() {
+	r.rollMutex.RLock()
+	interval := r.watchInterval
+	r.rollMutex.RUnlock()
+	if r.file == nil || interval <= 0 || time.Since(r.lastCheck) < interval {
+		return
+	}
+	r.lastCheck = time.Now()
+
+	opened, err := r.file.Stat()
+	if err != nil {
+		return
+	}
+	current, err := os.Stat(r.filePath)
+	if err == nil && os.SameFile(opened, current) {
+		return
+	}
+	r.reportError(r.closeFile())
+}
+
+// closeFile syncs and closes the active file, recording its final checkpoint.
+func (r *RollingFile) closeFile() error {
+	f := r.file
+	if f == nil {
+		return nil
+	}
+	r.file = nil
+
+	var err error
+	r.rollMutex.RLock()
+	policy := r.syncPolicy
+	r.rollMutex.RUnlock()
+	if policy != SyncNever {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		r.recordCheckpoint(true)
+	}
+	return err
+}
+
 // flushRoutine : ...
 func (r *RollingFile) flushRoutine() {
-	t := time.NewTicker(500 * time.Millisecond)
+	r.rollMutex.RLock()
+	t := time.NewTicker(r.flushInterval)
+	r.rollMutex.RUnlock()
 
+	// flush writes the buffers without holding mu, so writes aren't blocked
+	// by the file or by the write rate limit
 	flush := func() {
+		r.mu.Lock()
+		current := r.current
+		r.current = nil
+		r.mu.Unlock()
+
 		readyLen := len(r.fullBuffer)
 		for i := 0; i < readyLen; i++ {
 			buff := <-r.fullBuffer
 			r.writeBuffer(buff)
-			putBuffer(buff)
-		}
-
-		if r.current != nil {
-			r.writeBuffer(r.current)
-			putBuffer(r.current)
+			buff.recycle()
 		}
+		r.drainSpill()
 
-		r.current = nil
-		if r.file != nil {
-			r.file.Sync()
+		if current != nil {
+			r.writeBuffer(current)
+			current.recycle()
 		}
+		r.syncFile(false)
 	}
 
+	stopped := false
 	//FIXME better solution ?
 	defer func() {
 		t.Stop()
-		flush()
-		if f := r.file; f != nil {
-			r.file = nil
-			f.Close()
+		if stopped {
+			// idle, the next write starts it again
+			return
 		}
+		r.closing = true
+		flush()
+		r.removeSpill()
+		r.reportError(r.closeFile())
+		close(r.done)
 	}()
 
 	for {
 		select {
 		case <-r.syncFlush:
-			r.mu.Lock()
 			flush()
-			r.mu.Unlock()
 			r.syncFlush <- struct{}{}
+		case done := <-r.flush:
+			flush()
+			done <- r.persistError()
+		case done := <-r.reopen:
+			flush()
+			// the next write opens the file again by its path
+			done <- r.closeFile()
 		case buff := <-r.fullBuffer:
 			r.writeBuffer(buff)
-			putBuffer(buff)
+			buff.recycle()
+			r.drainSpill()
+			r.checkPressure()
+		case req := <-r.direct:
+			// keep order, buffers handed over before are written first
+			readyLen := len(r.fullBuffer)
+			for i := 0; i < readyLen; i++ {
+				buff := <-r.fullBuffer
+				r.writeBuffer(buff)
+				buff.recycle()
+			}
+			err := r.writeDirect(req.b)
+			if err == nil && req.sync {
+				atomic.AddInt64(&r.counters.Syncs, 1)
+				err = r.file.Sync()
+			}
+			req.done <- err
+		case <-r.lazyChanged:
+			if r.stopUnused() {
+				stopped = true
+				return
+			}
+		case <-r.intervalChanged:
+			r.rollMutex.RLock()
+			t.Reset(r.flushInterval)
+			r.rollMutex.RUnlock()
 		case <-t.C:
+			if r.stopIdle() {
+				stopped = true
+				return
+			}
+			r.drainSpill()
+			r.checkPressure()
+			r.syncFile(true)
+			r.checkFile()
 			r.mu.Lock()
 			if len(r.fullBuffer) != 0 {
 				r.mu.Unlock()
@@ -375,7 +1454,7 @@ func (r *RollingFile) flushRoutine() {
 			r.current = nil
 			r.mu.Unlock()
 			r.writeBuffer(buff)
-			putBuffer(buff)
+			buff.recycle()
 		case <-r.exit:
 			return
 		}
@@ -390,17 +1469,33 @@ func NewRollingFile(basePath string, rolling RollingFormat) (*RollingFile, error
 	}
 
 	r := &RollingFile{
-		basePath:   basePath,
-		rolling:    rolling,
-		exit:       make(chan struct{}),
-		syncFlush:  make(chan struct{}),
-		closed:     false,
-		fullBuffer: make(chan *bytes.Buffer, logPageNumber+1),
-		current:    getBuffer(),
-		fileExt:    defaultFileExt,
+		basePath:  basePath,
+		rolling:   rolling,
+		exit:      make(chan struct{}),
+		done:      make(chan struct{}),
+		syncFlush: make(chan struct{}),
+		direct:    make(chan directWrite),
+		reopen:    make(chan chan error),
+		flush:     make(chan chan error),
+
+		intervalChanged: make(chan struct{}, 1),
+		lazyChanged:     make(chan struct{}, 1),
+		flushInterval:   defaultFlushInterval,
+		stderr:          os.Stderr,
+		closed:          false,
+		fullBuffer:      make(chan *poolBuffer, logPageNumber+1),
+		flushThreshold:  logPageCacheByteSize,
+		fileExt:         defaultFileExt,
+		fileMode:        defaultFileMode,
+		dirMode:         defaultDirMode,
+		describeAt:      time.Now(),
 	}
+	r.pool.Store(bpool)
+	r.current = r.bufferPool().get()
 	// fill ready buffer
+	r.running = true
 	go r.flushRoutine()
+	registerRollingFile(r)
 
 	return r, nil
 }
@@ -0,0 +1,1075 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressFile(t *testing.T) {
+	for _, c := range []Compression{GzipCompression, ZstdCompression} {
+		src := filepath.Join(t.TempDir(), "info_01.log")
+		assert.NoError(t, os.WriteFile(src, []byte(msg), 0666))
+		assert.NoError(t, compressFile(src, c, 0))
+
+		_, err := os.Stat(src)
+		assert.True(t, os.IsNotExist(err))
+
+		f, err := os.Open(src + c.Ext())
+		assert.NoError(t, err)
+		var r io.Reader
+		if c == GzipCompression {
+			r, err = gzip.NewReader(f)
+		} else {
+			r, err = zstd.NewReader(f)
+		}
+		assert.NoError(t, err)
+		b, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, msg, string(b))
+		f.Close()
+	}
+}
+
+func TestRollingFile_FileExtAndMode(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetFileExt(".jsonl")
+	r.SetFileMode(0640)
+
+	_, err = r.Write([]byte(msg))
+	assert.NoError(t, err)
+	assert.NoError(t, r.Sync())
+	r.Close()
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "*", "*", "info_*.jsonl"))
+	assert.Len(t, matches, 1)
+	info, err := os.Stat(matches[0])
+	assert.NoError(t, err)
+	if runtime.GOOS == "windows" {
+		// only the write bit is honored
+		assert.Equal(t, os.FileMode(0666), info.Mode().Perm())
+	} else {
+		assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	}
+}
+
+func TestRollingFile_Symlink(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetSymlink(true)
+
+	_, err = r.Write([]byte(msg))
+	assert.NoError(t, err)
+	assert.NoError(t, r.Sync())
+	r.Close()
+
+	b, err := os.ReadFile(filepath.Join(dir, "info.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, msg, string(b))
+}
+
+func TestRollingFile_SymlinkFollowsRotation(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetRollingFunc(func(name string, t time.Time) (string, string) {
+		return "", name + "_1"
+	})
+	r.SetMaxSize(int64(len(msg)))
+	r.SetSymlink(true)
+
+	// the link, or the hard link fallback on Windows, moves to the new file
+	for _, s := range []string{msg, "second"} {
+		_, err = r.Write([]byte(s))
+		assert.NoError(t, err)
+		assert.NoError(t, r.Sync())
+		b, err := os.ReadFile(filepath.Join(dir, "info.log"))
+		assert.NoError(t, err)
+		assert.Equal(t, s, string(b))
+	}
+	assert.NoError(t, r.Close())
+}
+
+func TestRollingFile_SymlinkNamedLikeFile(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetRollingFunc(func(name string, t time.Time) (string, string) {
+		return "", name
+	})
+	r.SetSymlink(true)
+
+	// the active file is info.log, it's kept instead of being replaced by a link
+	for _, s := range []string{msg, "second"} {
+		_, err = r.Write([]byte(s))
+		assert.NoError(t, err)
+		assert.NoError(t, r.Sync())
+	}
+	assert.NoError(t, r.Close())
+
+	info, err := os.Lstat(filepath.Join(dir, "info.log"))
+	assert.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+	b, err := os.ReadFile(filepath.Join(dir, "info.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, msg+"second", string(b))
+}
+
+func TestRollingFile_SetBufferPoolSizeWhileWriting(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetRollingFunc(func(name string, t time.Time) (string, string) {
+		return "", name
+	})
+	r.SetFlushThreshold(64)
+	r.SetOverflowPolicy(OverflowBlock)
+
+	const writes = 500
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < writes; i++ {
+			r.Write([]byte(msg + "\n"))
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		r.SetBufferPoolSize(2 + i)
+	}
+	<-done
+	assert.NoError(t, r.Close())
+
+	b, err := os.ReadFile(filepath.Join(dir, "info.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, writes, strings.Count(string(b), msg))
+	// buffers taken before a swap went back to their own pool
+	assert.Equal(t, int64(0), atomic.LoadInt64(&r.bufferPool().count))
+}
+
+func TestRollingFile_LazyFlush(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetRollingFunc(func(name string, t time.Time) (string, string) {
+		return "", name
+	})
+	r.SetFlushInterval(10 * time.Millisecond)
+	running := func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.running
+	}
+	assert.True(t, running())
+	// stopped until the first write
+	r.SetLazyFlush(30 * time.Millisecond)
+	assert.Eventually(t, func() bool { return !running() }, time.Second, 5*time.Millisecond)
+
+	_, err = r.Write([]byte(msg))
+	assert.NoError(t, err)
+	assert.True(t, running())
+	// flushed by the ticker, then stopped idle
+	assert.Eventually(t, func() bool { return !running() }, time.Second, 5*time.Millisecond)
+	b, err := os.ReadFile(filepath.Join(dir, "info.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, msg, string(b))
+
+	_, err = r.Write([]byte(msg))
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+	b, err = os.ReadFile(filepath.Join(dir, "info.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, msg+msg, string(b))
+}
+
+func TestRollingFile_OverflowPolicy(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	defer r.Close()
+	// a pool that never hands out buffers
+	r.SetBufferPoolSize(-1)
+
+	_, err = r.Write([]byte(msg))
+	assert.Equal(t, ErrBuffer, err)
+	assert.Equal(t, int64(1), r.Dropped())
+
+	r.SetOverflowPolicy(OverflowDirect)
+	n, err := r.Write([]byte(msg))
+	assert.NoError(t, err)
+	assert.Equal(t, len(msg), n)
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "*", "*", "info_*.log"))
+	assert.Len(t, matches, 1)
+	b, _ := os.ReadFile(matches[0])
+	assert.Equal(t, msg, string(b))
+}
+
+func TestRollingFile_ErrorHandler(t *testing.T) {
+	dir := t.TempDir()
+	// a regular file where a directory is expected makes roll() fail
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "blocker"), nil, 0666))
+	r, err := NewRollingFile(filepath.Join(dir, "blocker", "info"), HourlyRolling)
+	assert.NoError(t, err)
+
+	var errs []error
+	r.SetErrorHandler(func(err error) {
+		errs = append(errs, err)
+	})
+	_, err = r.Write([]byte(msg))
+	assert.NoError(t, err)
+	assert.Error(t, r.Sync())
+	r.Close()
+
+	assert.NotEmpty(t, errs)
+}
+
+func TestRollingFile_DiskFullFallback(t *testing.T) {
+	full, err := os.OpenFile("/dev/full", os.O_WRONLY, 0)
+	if err != nil {
+		t.Skip("/dev/full is not available")
+	}
+
+	r, err := NewRollingFile(filepath.Join(t.TempDir(), "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetDiskFullFallback(true)
+	var stderr bytes.Buffer
+	r.stderr = &stderr
+
+	// open the file, then swap it for a device which is always full
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	r.file.Close()
+	r.file = full
+
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	r.Close()
+
+	assert.Contains(t, stderr.String(), "no space left on device")
+	assert.True(t, strings.HasSuffix(stderr.String(), msg))
+}
+
+func TestRollingFile_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetSymlink(true)
+
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	matches, _ := filepath.Glob(filepath.Join(dir, "*", "*", "info_*.log"))
+	assert.Len(t, matches, 1)
+	assert.NoError(t, os.Rename(matches[0], matches[0]+".1"))
+
+	assert.NoError(t, ReopenAll())
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	r.Close()
+	assert.Equal(t, ErrClosedRollingFile, r.Reopen())
+
+	b, err := os.ReadFile(matches[0])
+	assert.NoError(t, err)
+	assert.Equal(t, msg, string(b))
+}
+
+func TestRollingFile_RollingFunc(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetRollingFunc(func(name string, t time.Time) (string, string) {
+		year, week := t.ISOWeek()
+		return "weekly", fmt.Sprintf("%s-%04d-W%02d", name, year, week)
+	})
+
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	r.Close()
+
+	year, week := time.Now().ISOWeek()
+	b, err := os.ReadFile(filepath.Join(dir, "weekly", fmt.Sprintf("info-%04d-W%02d.log", year, week)))
+	assert.NoError(t, err)
+	assert.Equal(t, msg, string(b))
+}
+
+func TestRollingFile_Checkpoints(t *testing.T) {
+	r, err := NewRollingFile(filepath.Join(t.TempDir(), "info"), HourlyRolling)
+	assert.NoError(t, err)
+
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	checkpoints := r.Checkpoints()
+	assert.Len(t, checkpoints, 1)
+	assert.Equal(t, int64(len(msg)), checkpoints[0].Offset)
+	assert.False(t, checkpoints[0].Closed)
+
+	assert.NoError(t, r.Reopen())
+	cp, ok := r.Checkpoint(checkpoints[0].Path)
+	assert.True(t, ok)
+	assert.True(t, cp.Closed)
+	r.Close()
+}
+
+func TestRollingFile_SyncPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		policy   SyncPolicy
+		interval time.Duration
+		// offset is the checkpoint after two synced writes
+		offset int64
+		synced bool
+	}{
+		{SyncOnFlush, 0, int64(2 * len(msg)), true},
+		// the second write isn't fsynced before the interval
+		{SyncPeriodically, time.Hour, int64(len(msg)), true},
+		// checkpoints are recorded by flushes
+		{SyncNever, 0, int64(2 * len(msg)), false},
+	} {
+		r, err := NewRollingFile(filepath.Join(t.TempDir(), "info"), HourlyRolling)
+		assert.NoError(t, err)
+		r.SetSyncPolicy(tt.policy, tt.interval)
+
+		for i := 0; i < 2; i++ {
+			r.Write([]byte(msg))
+			assert.NoError(t, r.Sync())
+		}
+		checkpoints := r.Checkpoints()
+		assert.Len(t, checkpoints, 1)
+		assert.Equal(t, tt.offset, checkpoints[0].Offset, "policy %d", tt.policy)
+
+		assert.NoError(t, r.Close())
+		// the flush routine is done
+		assert.Equal(t, tt.synced, !r.lastSync.IsZero(), "policy %d", tt.policy)
+	}
+
+	opt := newOptions(WithSyncPolicy(SyncPeriodically, time.Second))
+	assert.Equal(t, SyncPeriodically, opt.syncPolicy)
+	assert.Equal(t, time.Second, opt.syncInterval)
+}
+
+func TestRollingFile_MaxSize(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetRollingFunc(func(name string, t time.Time) (string, string) {
+		return "", name
+	})
+	r.SetMaxSize(int64(len(msg)))
+
+	for i := 0; i < 3; i++ {
+		r.Write([]byte(msg))
+		assert.NoError(t, r.Sync())
+	}
+	r.Close()
+
+	for _, name := range []string{"info.log", "info.1.log", "info.2.log"} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		assert.Equal(t, msg, string(b))
+	}
+}
+
+func TestRollingFile_Location(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	loc := time.FixedZone("UTC+14", 14*60*60)
+	r.SetLocation(loc)
+
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	r.Close()
+
+	now := time.Now().In(loc)
+	_, err = os.Stat(filepath.Join(dir, now.Format("200601"), now.Format("02"), now.Format("info_15.log")))
+	assert.NoError(t, err)
+}
+
+func TestRollingFile_CloseReturnsFlushError(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "blocker"), nil, 0666))
+	r, err := NewRollingFile(filepath.Join(dir, "blocker", "info"), HourlyRolling)
+	assert.NoError(t, err)
+
+	r.Write([]byte(msg))
+	assert.Error(t, r.Close())
+	assert.NoError(t, r.Close())
+}
+
+func TestRollingFile_FlushInterval(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	defer r.Close()
+	r.SetSymlink(true)
+	r.SetFlushInterval(10 * time.Millisecond)
+
+	r.Write([]byte(msg))
+	assert.Eventually(t, func() bool {
+		b, _ := os.ReadFile(filepath.Join(dir, "info.log"))
+		return string(b) == msg
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRollingFile_WatchInterval(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	defer r.Close()
+	r.SetSymlink(true)
+	r.SetFlushInterval(5 * time.Millisecond)
+	r.SetWatchInterval(time.Millisecond)
+
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	path := r.Checkpoints()[0].Path
+	assert.NoError(t, os.Remove(path))
+
+	assert.Eventually(t, func() bool {
+		cp, _ := r.Checkpoint(path)
+		return cp.Closed
+	}, time.Second, 5*time.Millisecond)
+
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, string(b))
+}
+
+func TestRollingFile_Stats(t *testing.T) {
+	r, err := NewRollingFile(filepath.Join(t.TempDir(), "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetRollingFunc(func(name string, t time.Time) (string, string) {
+		return "", name
+	})
+	r.SetMaxSize(int64(len(msg)))
+
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	r.Close()
+
+	stats := r.Stats()
+	assert.Equal(t, int64(2), stats.Writes)
+	assert.Equal(t, int64(2*len(msg)), stats.BytesWritten)
+	assert.Equal(t, int64(1), stats.Rolls)
+	assert.False(t, stats.LastRoll.IsZero())
+	assert.NoError(t, stats.LastError)
+}
+
+func TestRollingFile_StatsWhileWriting(t *testing.T) {
+	r, err := NewRollingFile(filepath.Join(t.TempDir(), "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetMaxSize(int64(10 * len(msg)))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				r.Write([]byte(msg))
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for stop := false; !stop; {
+		select {
+		case <-done:
+			stop = true
+		default:
+		}
+		stats := r.Stats()
+		assert.LessOrEqual(t, stats.BytesWritten, stats.BytesAccepted)
+	}
+	assert.NoError(t, r.Close())
+	assert.Equal(t, int64(400), r.Stats().Writes)
+}
+
+func TestRollingFile_FileLock(t *testing.T) {
+	dir := t.TempDir()
+	line := strings.Repeat("x", 100) + "\n"
+	files := make([]*RollingFile, 2)
+	for i := range files {
+		r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+		assert.NoError(t, err)
+		r.SetSymlink(true)
+		r.SetFileLock(true)
+		files[i] = r
+	}
+	for i := 0; i < 200; i++ {
+		for _, r := range files {
+			r.Write([]byte(line))
+		}
+	}
+	for _, r := range files {
+		assert.NoError(t, r.Close())
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, "info.log"))
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	assert.Len(t, lines, 400)
+	for _, l := range lines {
+		assert.Equal(t, line, l+"\n")
+	}
+}
+
+func TestRollingFile_FileLockContention(t *testing.T) {
+	r, err := NewRollingFile(filepath.Join(t.TempDir(), "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetFileLock(true)
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	path := r.Checkpoints()[0].Path
+
+	// another process holding the lock
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	assert.NoError(t, err)
+	defer f.Close()
+	assert.NoError(t, lockFile(f))
+
+	r.Write([]byte(msg))
+	synced := make(chan error, 1)
+	go func() { synced <- r.Sync() }()
+	select {
+	case <-synced:
+		t.Fatal("written while the file is locked")
+	case <-time.After(50 * time.Millisecond):
+	}
+	_, err = f.WriteString(msg)
+	assert.NoError(t, err)
+	assert.NoError(t, unlockFile(f))
+	assert.NoError(t, <-synced)
+	assert.NoError(t, r.Close())
+
+	b, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat(msg, 3), string(b))
+	// the offset counts the writes of others
+	cp, _ := r.Checkpoint(path)
+	assert.Equal(t, int64(3*len(msg)), cp.Offset)
+}
+
+func TestRollingFile_RotateHooks(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetRollingFunc(func(name string, t time.Time) (string, string) {
+		return "", name
+	})
+	r.SetMaxSize(int64(len(msg)))
+	var rotated []string
+	r.SetOnBeforeRotate(func(path string, w io.Writer) {
+		io.WriteString(w, "EOF")
+	})
+	r.SetOnAfterRotate(func(oldPath, newPath string) {
+		rotated = append(rotated, oldPath, newPath)
+	})
+
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Close())
+
+	assert.Equal(t, []string{filepath.Join(dir, "info.log"), filepath.Join(dir, "info.1.log")}, rotated)
+	b, err := os.ReadFile(filepath.Join(dir, "info.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, msg+"EOF", string(b))
+}
+
+func TestRollingFile_FlatLayout(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetFlatLayout(true)
+
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Close())
+
+	_, err = os.Stat(filepath.Join(dir, "info-"+time.Now().Format(string(HourlyRolling))+".log"))
+	assert.NoError(t, err)
+}
+
+func TestRollingFile_FilenameTemplate(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), DailyRolling)
+	assert.NoError(t, err)
+	r.SetFilenameTemplate("{date}/{name}-{host}.{ext}")
+
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Close())
+
+	host, _ := os.Hostname()
+	_, err = os.Stat(filepath.Join(dir, time.Now().Format(string(DailyRolling)), "info-"+host+".log"))
+	assert.NoError(t, err)
+}
+
+func TestRollingFile_OverflowSpill(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetOverflowPolicy(OverflowSpill)
+	r.SetFlushThreshold(10)
+	release := make(chan struct{})
+	r.SetRollingFunc(func(name string, t time.Time) (string, string) {
+		<-release
+		return "", name
+	})
+
+	var want strings.Builder
+	for i := 0; i < 20; i++ {
+		line := fmt.Sprintf("line %02d\n", i)
+		want.WriteString(line)
+		n, err := r.Write([]byte(line))
+		assert.NoError(t, err)
+		assert.Equal(t, len(line), n)
+	}
+	assert.Greater(t, r.Stats().Spilled, int64(0))
+	close(release)
+	assert.NoError(t, r.Close())
+
+	b, err := os.ReadFile(filepath.Join(dir, "info.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, want.String(), string(b))
+	spills, _ := filepath.Glob(filepath.Join(dir, ".info-*.spill"))
+	assert.Empty(t, spills)
+}
+
+func TestRollingFile_OnPressure(t *testing.T) {
+	r, err := NewRollingFile(filepath.Join(t.TempDir(), "info"), HourlyRolling)
+	assert.NoError(t, err)
+	defer r.Close()
+	r.SetBufferPoolSize(4)
+	r.SetFlushThreshold(10)
+	r.SetFlushInterval(10 * time.Millisecond)
+	release := make(chan struct{})
+	r.SetRollingFunc(func(name string, t time.Time) (string, string) {
+		<-release
+		return "", name
+	})
+	states := make(chan bool, 2)
+	r.SetOnPressure(func(degraded bool) {
+		states <- degraded
+	})
+
+	// every write hands a buffer over, the flusher blocks on the first one
+	for i := 0; i < 4; i++ {
+		r.Write([]byte(msg))
+	}
+	assert.True(t, <-states)
+	assert.True(t, r.Degraded())
+
+	close(release)
+	select {
+	case degraded := <-states:
+		assert.False(t, degraded)
+	case <-time.After(time.Second):
+		t.Fatal("pressure not relieved")
+	}
+}
+
+func TestRollingFile_Preallocate(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetSymlink(true)
+	r.SetPreallocate(1 << 20)
+
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Close())
+	assert.NoError(t, r.Stats().LastError)
+
+	// the size of the file is kept, appends land right after the data
+	b, err := os.ReadFile(filepath.Join(dir, "info.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, msg, string(b))
+}
+
+func TestRollingFile_PersistError(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "blocker"), nil, 0666))
+	r, err := NewRollingFile(filepath.Join(dir, "blocker", "info"), HourlyRolling)
+	assert.NoError(t, err)
+	defer r.Close()
+
+	_, err = r.Write([]byte(msg))
+	assert.NoError(t, err)
+	assert.Error(t, r.Sync())
+	_, err = r.Write([]byte(msg))
+	assert.Error(t, err)
+
+	// recovers once the directory can be created
+	assert.NoError(t, os.Remove(filepath.Join(dir, "blocker")))
+	assert.NoError(t, r.Sync())
+	_, err = r.Write([]byte(msg))
+	assert.NoError(t, err)
+}
+
+func TestRollingFile_CompressionDelay(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetRollingFunc(func(name string, t time.Time) (string, string) {
+		return "", name
+	})
+	r.SetMaxSize(int64(len(msg)))
+	r.SetCompression(GzipCompression, 0)
+	r.SetCompressionDelay(100 * time.Millisecond)
+
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Close())
+
+	// still appendable within the grace period
+	_, err = os.Stat(filepath.Join(dir, "info.log"))
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "info.log.gz"))
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRollingFile_DirectWrite(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	defer r.Close()
+	r.SetSymlink(true)
+	r.SetDirectWrite(true, true)
+
+	n, err := r.Write([]byte(msg))
+	assert.NoError(t, err)
+	assert.Equal(t, len(msg), n)
+
+	// persisted without Sync
+	b, err := os.ReadFile(filepath.Join(dir, "info.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, msg, string(b))
+}
+
+func TestRollingFile_Header(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetSymlink(true)
+	r.SetHeader(map[string]interface{}{"app_version": "1.2"})
+
+	r.Write([]byte(msg + "\n"))
+	assert.NoError(t, r.Close())
+
+	b, err := os.ReadFile(filepath.Join(dir, "info.log"))
+	assert.NoError(t, err)
+	lines := strings.Split(string(b), "\n")
+	var header map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &header))
+	assert.Equal(t, "1.2", header["app_version"])
+	assert.Equal(t, float64(os.Getpid()), header["pid"])
+	assert.Equal(t, true, header["log_header"])
+	assert.Equal(t, msg, lines[1])
+}
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) *time.Ticker {
+	return time.NewTicker(d)
+}
+
+func (c *fakeClock) Add(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestRollingFile_Clock(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 10, 59, 59, 0, time.UTC)}
+	r.SetClock(clock)
+	r.SetLocation(time.UTC)
+
+	// an hour boundary is crossed between the writes
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	clock.Add(time.Second)
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Close())
+
+	var names []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	assert.Equal(t, []string{"202001/01/info_10.log", "202001/01/info_11.log"}, names)
+}
+
+func TestRollingFile_Describe(t *testing.T) {
+	r, err := NewRollingFile(filepath.Join(t.TempDir(), "info"), HourlyRolling)
+	assert.NoError(t, err)
+	defer r.Close()
+
+	for i := 0; i < 3; i++ {
+		r.Write([]byte(msg))
+	}
+	assert.NoError(t, r.Sync())
+
+	report := r.Describe()
+	assert.Equal(t, int64(3*len(msg)), report.BytesAccepted)
+	assert.Equal(t, int64(3*len(msg)), report.BytesWritten)
+	assert.Equal(t, int64(1), report.FileWrites)
+	assert.Equal(t, int64(1), report.Syncs)
+	// a sync within a moment is a high rate
+	assert.Contains(t, strings.Join(report.Suggestions, "\n"), "SyncPeriodically")
+
+	// measured since the previous call
+	assert.Zero(t, r.Describe().BytesAccepted)
+}
+
+func TestRollingFile_Manifest(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetRollingFunc(func(name string, t time.Time) (string, string) {
+		return "", name
+	})
+	r.SetMaxSize(int64(len(msg)))
+	r.SetManifest(true)
+	r.SetFileMode(0640)
+
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Close())
+
+	path := filepath.Join(dir, "info.log")
+	assert.Eventually(t, func() bool {
+		return VerifyManifest(path) == nil
+	}, time.Second, 10*time.Millisecond)
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path + manifestExt)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	}
+
+	assert.NoError(t, os.Truncate(path, 3))
+	assert.Equal(t, ErrManifestMismatch, VerifyManifest(path))
+}
+
+func TestRollingFile_MaxTotalSize(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetRollingFunc(func(name string, t time.Time) (string, string) {
+		return "", name
+	})
+	r.SetMaxSize(int64(len(msg)))
+	r.SetMaxTotalSize(int64(2 * len(msg)))
+	// hold evictions until the active file is written
+	written := make(chan struct{})
+	r.SetOnRotated(func(path string) error {
+		<-written
+		return nil
+	})
+
+	for i := 0; i < 4; i++ {
+		r.Write([]byte(msg))
+		assert.NoError(t, r.Sync())
+		// distinct modification times
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(written)
+
+	// the active file and the newest rotated one are kept
+	assert.Eventually(t, func() bool {
+		matches, _ := filepath.Glob(filepath.Join(dir, "info*.log"))
+		return len(matches) == 2
+	}, time.Second, 10*time.Millisecond)
+	_, err = os.Stat(filepath.Join(dir, "info.3.log"))
+	assert.NoError(t, err)
+	_, err = os.Stat(filepath.Join(dir, "info.2.log"))
+	assert.NoError(t, err)
+	assert.NoError(t, r.Close())
+}
+
+func TestRollingFile_WriteRateLimitUnlocked(t *testing.T) {
+	r, err := NewRollingFile(filepath.Join(t.TempDir(), "info"), HourlyRolling)
+	assert.NoError(t, err)
+	defer r.Close()
+	r.SetWriteRateLimit(1000)
+
+	// the second half waits for half a second while syncing
+	r.Write(bytes.Repeat([]byte("x"), 1500))
+	synced := make(chan error)
+	go func() {
+		synced <- r.Sync()
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	start := time.Now()
+	_, err = r.Write([]byte(msg))
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+	assert.NoError(t, <-synced)
+}
+
+func TestRollingFile_MaxTotalSizeOwnFiles(t *testing.T) {
+	dir := t.TempDir()
+	other := filepath.Join(dir, "other.log")
+	hidden := filepath.Join(dir, ".info.seq")
+	assert.NoError(t, os.WriteFile(other, bytes.Repeat([]byte("x"), 100), 0644))
+	assert.NoError(t, os.WriteFile(hidden, bytes.Repeat([]byte("x"), 100), 0644))
+	assert.NoError(t, os.Chtimes(other, time.Now().Add(-time.Hour), time.Now().Add(-time.Hour)))
+
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetRollingFunc(func(name string, t time.Time) (string, string) {
+		return "", name
+	})
+	r.SetMaxSize(int64(len(msg)))
+	r.SetMaxTotalSize(int64(2 * len(msg)))
+	rotated := make(chan struct{}, 4)
+	r.SetOnRotated(func(path string) error {
+		rotated <- struct{}{}
+		return nil
+	})
+
+	// the eviction of a rotation may run before the next file is written,
+	// the last one sees at least three own files
+	for i := 0; i < 4; i++ {
+		r.Write([]byte(msg))
+		assert.NoError(t, r.Sync())
+		time.Sleep(10 * time.Millisecond)
+	}
+	<-rotated
+	<-rotated
+	<-rotated
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "info.log"))
+		return os.IsNotExist(err)
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, r.Close())
+
+	// files of others are neither counted nor removed
+	for _, path := range []string{other, hidden, filepath.Join(dir, "info.3.log"), filepath.Join(dir, "info.2.log")} {
+		_, err = os.Stat(path)
+		assert.NoError(t, err)
+	}
+}
+
+func TestRollingFile_OwnsFile(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "app"), DailyRolling)
+	assert.NoError(t, err)
+	defer r.Close()
+
+	for rel, owned := range map[string]bool{
+		"201901/app_15.log":                   true,
+		"201901/app_15.2.log.gz":              true,
+		"201901/app_15.log.zst.manifest.json": true,
+		"201901/app_access_15.log":            false,
+		"201901/app_15.txt":                   false,
+		"201901/15/app_03.log":                false,
+		"app-20190115.log":                    false,
+		"app.log":                             false,
+		"access/201901/app_15.log":            false,
+		"201901/app_15.x.log":                 false,
+	} {
+		assert.Equal(t, owned, r.ownsFile(filepath.FromSlash(rel)), rel)
+	}
+	assert.Equal(t, 1, r.fileDepth())
+
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	r.SetFlatLayout(true)
+	assert.True(t, r.ownsFile("app-20190115.3.log"))
+	assert.False(t, r.ownsFile("app_access-20190115.log"))
+	assert.Equal(t, 1, r.fileDepth(), "the active file is still nested")
+
+	r.SetFlatLayout(false)
+	r.SetFilenameTemplate("{date}/{name}-{pid}.{ext}")
+	assert.True(t, r.ownsFile(filepath.FromSlash("20190115/app-1234.log")))
+	assert.False(t, r.ownsFile(filepath.FromSlash("20190115/app_access-1234.log")))
+}
+
+func TestRollingFile_MaxTotalSizeOtherOutputs(t *testing.T) {
+	dir := t.TempDir()
+	others := []string{
+		filepath.Join(dir, "201901", "app_access_15.log"),
+		filepath.Join(dir, "access", "201901", "app_15.log"),
+		filepath.Join(dir, "201901", "15", "app_03.log"),
+	}
+	for _, path := range others {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(t, os.WriteFile(path, bytes.Repeat([]byte("x"), 100), 0644))
+	}
+	old := filepath.Join(dir, "201901", "app_14.log")
+	assert.NoError(t, os.WriteFile(old, bytes.Repeat([]byte("x"), 100), 0644))
+
+	r, err := NewRollingFile(filepath.Join(dir, "app"), DailyRolling)
+	assert.NoError(t, err)
+	defer r.Close()
+	r.SetMaxTotalSize(1)
+	r.SetMaxSize(10)
+	r.Write(bytes.Repeat([]byte("x"), 20))
+	assert.NoError(t, r.Sync())
+	r.Write([]byte("x"))
+	assert.NoError(t, r.Sync())
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(old)
+		return os.IsNotExist(err)
+	}, time.Second, 10*time.Millisecond)
+	for _, path := range others {
+		assert.FileExists(t, path)
+	}
+}
+
+func TestRollingFile_WriteRateLimit(t *testing.T) {
+	r, err := NewRollingFile(filepath.Join(t.TempDir(), "info"), HourlyRolling)
+	assert.NoError(t, err)
+	defer r.Close()
+	r.SetWriteRateLimit(1000)
+
+	// the burst of the first second passes, the rest waits
+	start := time.Now()
+	r.Write(bytes.Repeat([]byte("x"), 1000))
+	assert.NoError(t, r.Sync())
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+	r.Write(bytes.Repeat([]byte("x"), 200))
+	assert.NoError(t, r.Sync())
+	assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+}
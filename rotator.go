@@ -0,0 +1,20 @@
+package logger
+
+import (
+	"io"
+
+	"go.uber.org/zap/zapcore"
+)
+
+var _ RotatingWriter = (*RollingFile)(nil)
+
+// RotatingWriter is a writer of log files rotating them by itself, such as
+// RollingFile, which is the default one.
+type RotatingWriter interface {
+	zapcore.WriteSyncer
+	io.Closer
+}
+
+// Rotator creates the RotatingWriter of a log file, path is the log file
+// without extension, such as basePath/info.
+type Rotator func(path string) (RotatingWriter, error)
@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// RoutingRule routes the entries matching all its conditions to pipelines.
+// A pipeline named by any rule only receives entries matched by one of its
+// rules, so rules naming the same pipeline are ORed, while pipelines not
+// named by rules receive every entry.
+type RoutingRule struct {
+	// Level is the minimum level of matched entries, 0 matches all levels.
+	Level Level
+	// Fields is the values fields of matched entries must be equal to.
+	Fields map[string]interface{}
+	// Outputs is the names of the pipelines matched entries are routed to.
+	Outputs []string
+}
+
+type routeMatcher struct {
+	level  zapcore.Level
+	any    bool
+	fields []zapcore.Field
+}
+
+func (m routeMatcher) match(ent *zapcore.Entry, fields []zapcore.Field) bool {
+	if !m.any && ent.Level < m.level {
+		return false
+	}
+	for _, want := range m.fields {
+		found := false
+		for _, f := range fields {
+			if f.Key == want.Key && f.Equals(want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// compileRoutes returns a Transform dropping the entries not routed to the
+// pipeline name, nil if no rule names it.
+func compileRoutes(rules []RoutingRule, name string) Transform {
+	if name == "" {
+		return nil
+	}
+	var matchers []routeMatcher
+	for _, rule := range rules {
+		if !containsString(rule.Outputs, name) {
+			continue
+		}
+		m := routeMatcher{level: rule.Level.unmarshalZapLevel(), any: rule.Level == 0}
+		for k, v := range rule.Fields {
+			m.fields = append(m.fields, zap.Any(k, v))
+		}
+		matchers = append(matchers, m)
+	}
+	if len(matchers) == 0 {
+		return nil
+	}
+
+	return func(ent *zapcore.Entry, fields []zapcore.Field) ([]zapcore.Field, bool) {
+		for _, m := range matchers {
+			if m.match(ent, fields) {
+				return fields, true
+			}
+		}
+		return fields, false
+	}
+}
@@ -0,0 +1,32 @@
+package logger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestRoutingRules(t *testing.T) {
+	var siem, all bytes.Buffer
+	cfg := newOptions().encoderConfig
+	cfg.TimeKey, cfg.CallerKey, cfg.LevelKey = "", "", ""
+	log := New(
+		WithConsole(false),
+		WithDisableDisk(true),
+		WithPipeline(Pipeline{Name: "siem", Output: zapcore.AddSync(&siem), Encoder: zapcore.NewJSONEncoder(cfg)}),
+		WithPipeline(Pipeline{Name: "all", Output: zapcore.AddSync(&all), Encoder: zapcore.NewJSONEncoder(cfg)}),
+		WithRoutingRules(
+			RoutingRule{Level: ErrorLevel, Outputs: []string{"siem"}},
+			RoutingRule{Fields: map[string]interface{}{"security": true}, Outputs: []string{"siem"}},
+		),
+	)
+
+	log.Infow("login", "security", true)
+	log.Infow("request", "security", false)
+	log.Error("failure")
+	assert.Equal(t, `{"msg":"login","security":true}`+"\n"+`{"msg":"failure"}`+"\n", siem.String())
+	assert.Equal(t, 3, strings.Count(all.String(), "\n"))
+}
@@ -0,0 +1,30 @@
+package logger
+
+import (
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"go.uber.org/zap"
+)
+
+// runtimeFields returns the hostname, pid, go version and app version fields.
+// The app version is version, or the version of the main module from the
+// build info if empty, and is left out if neither is known.
+func runtimeFields(version string) []zap.Field {
+	host, _ := os.Hostname()
+	fields := []zap.Field{
+		zap.String("hostname", host),
+		zap.Int("pid", os.Getpid()),
+		zap.String("go_version", runtime.Version()),
+	}
+	if version == "" {
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "(devel)" {
+			version = info.Main.Version
+		}
+	}
+	if version != "" {
+		fields = append(fields, zap.String("app_version", version))
+	}
+	return fields
+}
@@ -0,0 +1,139 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// sequenceBlock is the number of sequence numbers reserved at once, so the
+// state file is written once per block instead of once per entry.
+const sequenceBlock = 1000
+
+// sequence is a counter persisted in a state file. Numbers are reserved by
+// blocks, so numbers up to the end of a block are skipped after a crash, but
+// never reused. Sync persists the exact next number.
+type sequence struct {
+	mu       sync.Mutex
+	path     string
+	fileMode os.FileMode
+	dirMode  os.FileMode
+	next     uint64
+	reserved uint64
+}
+
+// newSequence loads the sequence persisted at path, starting from 1 if none.
+// The state file and its directory are created with fileMode and dirMode.
+func newSequence(path string, fileMode, dirMode os.FileMode) (*sequence, error) {
+	s := &sequence{path: path, fileMode: fileMode, dirMode: dirMode, next: 1}
+	b, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(b) > 0 {
+		if s.next, err = strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	s.reserved = s.next
+	return s, nil
+}
+
+// persist writes n to the state file atomically.
+func (s *sequence) persist(n uint64) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), s.dirMode); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatUint(n, 10)), platformFileMode(s.fileMode)); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// take returns the next number, the caller holds mu.
+func (s *sequence) take() (uint64, error) {
+	if s.next >= s.reserved {
+		if err := s.persist(s.next + sequenceBlock); err != nil {
+			return 0, err
+		}
+		s.reserved = s.next + sequenceBlock
+	}
+	n := s.next
+	s.next++
+	return n, nil
+}
+
+func (s *sequence) sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.persist(s.next); err != nil {
+		return err
+	}
+	s.reserved = s.next
+	return nil
+}
+
+// sequenceCore stamps entries with the sequence number of their output.
+type sequenceCore struct {
+	zapcore.Core
+	key string
+	seq *sequence
+}
+
+// newSequenceCore stamps the entries of the file output filename, sequences
+// of files are shared by the cores writing to them.
+func (l *logger) newSequenceCore(core zapcore.Core, filename string, seqs map[string]*sequence) (zapcore.Core, error) {
+	if l.opt.sequenceKey == "" {
+		return core, nil
+	}
+	seq, ok := seqs[filename]
+	if !ok {
+		var err error
+		dir, name := filepath.Split(filepath.Join(l.opt.basePath, filename))
+		if seq, err = newSequence(filepath.Join(dir, "."+name+".seq"), l.opt.fileMode, l.opt.dirMode); err != nil {
+			return nil, err
+		}
+		seqs[filename] = seq
+		l._sequences = append(l._sequences, seq)
+	}
+	return &sequenceCore{Core: core, key: l.opt.sequenceKey, seq: seq}, nil
+}
+
+func (c *sequenceCore) With(fields []zapcore.Field) zapcore.Core {
+	return &sequenceCore{Core: c.Core.With(fields), key: c.key, seq: c.seq}
+}
+
+func (c *sequenceCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *sequenceCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	// hold the lock while writing, so entries reach the output in sequence order
+	c.seq.mu.Lock()
+	defer c.seq.mu.Unlock()
+
+	n, err := c.seq.take()
+	if err != nil {
+		return err
+	}
+	all := make([]zapcore.Field, 0, len(fields)+1)
+	all = append(append(all, zap.Uint64(c.key, n)), fields...)
+	return c.Core.Write(ent, all)
+}
+
+func (c *sequenceCore) Sync() error {
+	if err := c.Core.Sync(); err != nil {
+		return err
+	}
+	return c.seq.sync()
+}
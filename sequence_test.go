@@ -0,0 +1,99 @@
+package logger
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequence(t *testing.T) {
+	dir := t.TempDir()
+	newLogger := func() Logger {
+		return New(
+			WithBasePath(dir),
+			WithConsole(false),
+			WithDisableDisk(false),
+			WithFilename("app"),
+			WithRollingFunc(func(name string, t time.Time) (string, string) {
+				return "", name
+			}),
+			WithSequence("seq"),
+		)
+	}
+
+	log := newLogger()
+	log.Info(msg)
+	log.Info(msg)
+	assert.NoError(t, log.Sync())
+
+	// restarted
+	log = newLogger()
+	log.Info(msg)
+	assert.NoError(t, log.Sync())
+
+	f, err := os.Open(filepath.Join(dir, "app.log"))
+	assert.NoError(t, err)
+	defer f.Close()
+	var seqs []float64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var m map[string]interface{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &m))
+		seqs = append(seqs, m["seq"].(float64))
+	}
+	assert.Equal(t, []float64{1, 2, 3}, seqs)
+}
+
+func TestSequenceClose(t *testing.T) {
+	dir := t.TempDir()
+	newLogger := func() *logger {
+		return New(
+			WithBasePath(dir),
+			WithConsole(false),
+			WithDisableDisk(false),
+			WithFilename("app"),
+			WithSequence("seq"),
+		).(*logger)
+	}
+
+	log := newLogger()
+	log.Info(msg)
+	assert.NoError(t, log.Close(context.Background()))
+
+	// numbers reserved but not used aren't skipped after a restart
+	b, err := os.ReadFile(filepath.Join(dir, ".app.seq"))
+	assert.NoError(t, err)
+	assert.Equal(t, "2", string(b))
+}
+
+func TestSequenceModes(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits aren't honored on windows")
+	}
+	dir := filepath.Join(t.TempDir(), "logs")
+	log := New(
+		WithBasePath(dir),
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithFilename("app"),
+		WithFileMode(0640),
+		WithDirMode(0750),
+		WithSequence("seq"),
+	).(*logger)
+	log.Info(msg)
+	assert.NoError(t, log.Close(context.Background()))
+
+	info, err := os.Stat(filepath.Join(dir, ".app.seq"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	info, err = os.Stat(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), info.Mode().Perm())
+}
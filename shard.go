@@ -0,0 +1,179 @@
+package logger
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+const defaultMaxOpenShards = 64
+
+// shardCore writes entries carrying a field to one rolling file per value of
+// the field, such as one file per job id.
+type shardCore struct {
+	zapcore.LevelEnabler
+	enc    zapcore.Encoder
+	key    string
+	fields []zapcore.Field
+	shards *shardSet
+}
+
+// shardSet keeps at most maxOpen rolling files open, closing the least
+// recently used one beyond that.
+type shardSet struct {
+	mutex   sync.Mutex
+	open    func(value string) (zapcore.WriteSyncer, error)
+	maxOpen int
+	files   map[string]*list.Element
+	lru     *list.List
+}
+
+type shardFile struct {
+	value string
+	ws    zapcore.WriteSyncer
+}
+
+func (l *logger) buildShards() *shardCore {
+	maxOpen := l.opt.maxOpenShards
+	if maxOpen <= 0 {
+		maxOpen = defaultMaxOpenShards
+	}
+	key := l.opt.shardKey
+	return &shardCore{
+		LevelEnabler: l.atomicLevel,
+		enc:          l.buildFileEncoder(),
+		key:          key,
+		shards: &shardSet{
+			open: func(value string) (zapcore.WriteSyncer, error) {
+				return l.createOutput(path.Join(key, value))
+			},
+			maxOpen: maxOpen,
+			files:   make(map[string]*list.Element),
+			lru:     list.New(),
+		},
+	}
+}
+
+func (c *shardCore) With(fields []zapcore.Field) zapcore.Core {
+	_copy := *c
+	_copy.enc = c.enc.Clone()
+	for i := range fields {
+		fields[i].AddTo(_copy.enc)
+	}
+	_copy.fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	return &_copy
+}
+
+func (c *shardCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *shardCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	value, ok := shardValue(c.key, fields)
+	if !ok {
+		if value, ok = shardValue(c.key, c.fields); !ok {
+			return nil
+		}
+	}
+
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	defer buf.Free()
+	return c.shards.write(value, buf.Bytes())
+}
+
+func (c *shardCore) Sync() error {
+	return c.shards.Sync()
+}
+
+// shardValue returns the value of the last field with key, made safe to be
+// used as a file name.
+func shardValue(key string, fields []zapcore.Field) (string, bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Key != key || fields[i].Type == zapcore.SkipType {
+			continue
+		}
+		enc := zapcore.NewMapObjectEncoder()
+		fields[i].AddTo(enc)
+		value := strings.Map(func(r rune) rune {
+			if r == '/' || r == '\\' || r == ':' {
+				return '_'
+			}
+			return r
+		}, fmt.Sprint(enc.Fields[key]))
+		if value == "" || value == "." || value == ".." {
+			return "_", true
+		}
+		return value, true
+	}
+	return "", false
+}
+
+func (s *shardSet) write(value string, b []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, ok := s.files[value]
+	if ok {
+		s.lru.MoveToFront(e)
+	} else {
+		ws, err := s.open(value)
+		if err != nil {
+			return err
+		}
+		e = s.lru.PushFront(&shardFile{value: value, ws: ws})
+		s.files[value] = e
+		for s.lru.Len() > s.maxOpen {
+			s.closeFile(s.lru.Back())
+		}
+	}
+	_, err := e.Value.(*shardFile).ws.Write(b)
+	return err
+}
+
+func (s *shardSet) closeFile(e *list.Element) {
+	f := s.lru.Remove(e).(*shardFile)
+	delete(s.files, f.value)
+	if c, ok := f.ws.(io.Closer); ok {
+		c.Close()
+	}
+}
+
+// Sync flushes every open shard file.
+func (s *shardSet) Sync() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var err error
+	for e := s.lru.Front(); e != nil; e = e.Next() {
+		err = multierr.Append(err, e.Value.(*shardFile).ws.Sync())
+	}
+	return err
+}
+
+// Close closes every open shard file.
+func (s *shardSet) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var err error
+	for e := s.lru.Front(); e != nil; e = s.lru.Front() {
+		f := s.lru.Remove(e).(*shardFile)
+		delete(s.files, f.value)
+		if c, ok := f.ws.(io.Closer); ok {
+			err = multierr.Append(err, c.Close())
+		}
+	}
+	return err
+}
@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardField(t *testing.T) {
+	dir := t.TempDir()
+	log := New(
+		WithBasePath(dir),
+		WithConsole(false),
+		WithDisableDisk(false),
+		WithFilename("app"),
+		WithRollingFunc(func(name string, t time.Time) (string, string) {
+			return "", name
+		}),
+		WithShardField("job_id", 1),
+	).(*logger)
+
+	log.Infow(msg, "job_id", 1)
+	log.WithFields(map[string]interface{}{"job_id": "a/b"}).Info(msg)
+	log.Infow(msg, "job_id", 1)
+	log.Info(msg)
+	assert.NoError(t, log.Sync())
+
+	// the file of job 1 was closed when a/b was opened, and reopened
+	b, err := os.ReadFile(filepath.Join(dir, "job_id", "1.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(b), msg))
+	b, err = os.ReadFile(filepath.Join(dir, "job_id", "a_b.log"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(b), msg))
+}
@@ -0,0 +1,216 @@
+//go:build testutil
+// +build testutil
+
+package logger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SoakConfig configures Soak.
+type SoakConfig struct {
+	// Dir is the directory log files are written to, it should be empty.
+	Dir string
+	// Writers is the number of concurrent writers.
+	Writers int
+	// Lines is the number of lines written by every writer.
+	Lines int
+	// ChaosInterval is the interval of random Sync, Reopen and rolling calls, 0 disables them.
+	ChaosInterval time.Duration
+	// Close closes the file at a random moment while writers are still running.
+	Close bool
+	// InjectErrors makes rolling fail now and then, lines of failed buffers are lost.
+	InjectErrors bool
+	// Seed seeds the random source, runs with the same seed make the same calls.
+	Seed int64
+}
+
+// SoakReport is the outcome of Soak.
+type SoakReport struct {
+	// Written is the number of lines accepted by Write.
+	Written int64
+	// Found is the number of complete lines found in the log files.
+	Found int64
+	// Lost is the number of accepted lines missing from the log files.
+	Lost int64
+	// Duplicated is the number of lines found more than once.
+	Duplicated int64
+	// Corrupted is the number of partial or interleaved lines.
+	Corrupted int64
+	// Errors is the number of errors reported by the error handler.
+	Errors int64
+}
+
+// Verify returns an error when lines are corrupted or duplicated, or lost
+// although no error was reported.
+func (r SoakReport) Verify() error {
+	switch {
+	case r.Corrupted > 0:
+		return fmt.Errorf("%d corrupted lines", r.Corrupted)
+	case r.Duplicated > 0:
+		return fmt.Errorf("%d duplicated lines", r.Duplicated)
+	case r.Lost > 0 && r.Errors == 0:
+		return fmt.Errorf("%d lines lost silently", r.Lost)
+	}
+	return nil
+}
+
+var soakLine = regexp.MustCompile(`^soak w=(\d+) n=(\d+) len=(\d+) pad=(x*)$`)
+
+// Soak hammers a RollingFile with concurrent writers and random Sync, Reopen,
+// Close and rolling calls, optionally failing disk writes, then checks every
+// accepted line was written exactly once and no line is partial or
+// interleaved. It is a reproducible verification tool for the write path.
+func Soak(cfg SoakConfig) (SoakReport, error) {
+	var report SoakReport
+	if cfg.Writers <= 0 || cfg.Lines <= 0 {
+		return report, errors.New("writers and lines must be positive")
+	}
+
+	r, err := NewRollingFile(filepath.Join(cfg.Dir, "soak"), HourlyRolling)
+	if err != nil {
+		return report, err
+	}
+	r.SetErrorHandler(func(error) {
+		atomic.AddInt64(&report.Errors, 1)
+	})
+
+	// a regular file named like a directory makes roll fail
+	var failing int32
+	if cfg.InjectErrors {
+		if err = os.WriteFile(filepath.Join(cfg.Dir, "blocked"), nil, 0666); err != nil {
+			return report, err
+		}
+	}
+	r.SetRollingFunc(func(name string, t time.Time) (string, string) {
+		if atomic.LoadInt32(&failing) == 1 {
+			return "blocked", name
+		}
+		return "", name
+	})
+
+	accepted := make([][]bool, cfg.Writers)
+	var wg sync.WaitGroup
+	for w := 0; w < cfg.Writers; w++ {
+		accepted[w] = make([]bool, cfg.Lines)
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			rnd := rand.New(rand.NewSource(cfg.Seed + int64(w)))
+			for n := 0; n < cfg.Lines; n++ {
+				pad := rnd.Intn(2 * logPageCacheByteSize)
+				line := fmt.Sprintf("soak w=%d n=%d len=%d pad=%s\n", w, n, pad, strings.Repeat("x", pad))
+				if _, err := r.Write([]byte(line)); err == nil {
+					accepted[w][n] = true
+					atomic.AddInt64(&report.Written, 1)
+				}
+			}
+		}(w)
+	}
+
+	stop := make(chan struct{})
+	chaosDone := make(chan struct{})
+	go func() {
+		defer close(chaosDone)
+		if cfg.ChaosInterval <= 0 {
+			return
+		}
+		rnd := rand.New(rand.NewSource(cfg.Seed))
+		t := time.NewTicker(cfg.ChaosInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+			}
+			switch rnd.Intn(6) {
+			case 0, 1:
+				r.Sync()
+			case 2:
+				r.Reopen()
+			case 3:
+				r.SetMaxSize(int64(rnd.Intn(8 * logPageCacheByteSize)))
+			case 4:
+				if cfg.InjectErrors {
+					atomic.StoreInt32(&failing, 1-atomic.LoadInt32(&failing))
+				}
+			case 5:
+				if cfg.Close && rnd.Intn(20) == 0 {
+					r.Close()
+				}
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(stop)
+	<-chaosDone
+	atomic.StoreInt32(&failing, 0)
+	r.Close()
+
+	found := make([][]int, cfg.Writers)
+	for w := range found {
+		found[w] = make([]int, cfg.Lines)
+	}
+	err = filepath.Walk(cfg.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() || !strings.HasPrefix(info.Name(), "soak") {
+			return err
+		}
+		return scanSoakFile(path, found, &report)
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for w := range found {
+		for n, count := range found[w] {
+			if count > 1 {
+				report.Duplicated += int64(count - 1)
+			}
+			if accepted[w][n] && count == 0 {
+				report.Lost++
+			}
+		}
+	}
+	return report, nil
+}
+
+func scanSoakFile(path string, found [][]int, report *SoakReport) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 4*logPageCacheByteSize), 64*logPageCacheByteSize)
+	for scanner.Scan() {
+		m := soakLine.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			report.Corrupted++
+			continue
+		}
+		w, _ := strconv.Atoi(m[1])
+		n, _ := strconv.Atoi(m[2])
+		pad, _ := strconv.Atoi(m[3])
+		if w >= len(found) || n >= len(found[w]) || pad != len(m[4]) {
+			report.Corrupted++
+			continue
+		}
+		found[w][n]++
+		report.Found++
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,38 @@
+//go:build testutil
+// +build testutil
+
+package logger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSoak(t *testing.T) {
+	report, err := Soak(SoakConfig{
+		Dir:           t.TempDir(),
+		Writers:       8,
+		Lines:         200,
+		ChaosInterval: time.Millisecond,
+		Seed:          1,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, report.Verify())
+	assert.Equal(t, report.Written, report.Found)
+}
+
+func TestSoak_InjectErrors(t *testing.T) {
+	report, err := Soak(SoakConfig{
+		Dir:           t.TempDir(),
+		Writers:       8,
+		Lines:         200,
+		ChaosInterval: time.Millisecond,
+		Close:         true,
+		InjectErrors:  true,
+		Seed:          2,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, report.Verify())
+}
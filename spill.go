@@ -0,0 +1,116 @@
+package logger
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// spillChunkSize is the bytes of the spill queue written to the file at once.
+const spillChunkSize = 64 * 1024
+
+// handOver passes a full buffer to the flush routine, buffers are spilled to
+// the on-disk queue when the flush routine lags behind, and as long as the
+// queue isn't drained to keep entries in order.
+func (r *RollingFile) handOver(buf *poolBuffer) {
+	r.spillMutex.Lock()
+	defer r.spillMutex.Unlock()
+
+	if !r.spilled {
+		select {
+		case r.fullBuffer <- buf:
+			return
+		default:
+		}
+	}
+	r.spill(buf.Bytes())
+	buf.recycle()
+}
+
+// spill appends b to the spill queue, the caller holds spillMutex.
+func (r *RollingFile) spill(b []byte) {
+	if r.spillFile == nil {
+		dir, name := filepath.Split(r.basePath)
+		if dir == "" {
+			dir = "."
+		}
+		f, err := os.CreateTemp(dir, "."+name+"-*.spill")
+		if err != nil {
+			atomic.AddInt64(&r.dropped, 1)
+			r.handleError(err)
+			return
+		}
+		r.spillFile = f
+	}
+
+	if _, err := r.spillFile.Write(b); err != nil {
+		atomic.AddInt64(&r.dropped, 1)
+		r.handleError(err)
+		return
+	}
+	r.spilled = true
+	atomic.StoreInt32(&r.spillPending, 1)
+	atomic.AddInt64(&r.counters.Spilled, int64(len(b)))
+}
+
+// drainSpill writes the spill queue to the file, it's called by the flush
+// routine only.
+func (r *RollingFile) drainSpill() {
+	if atomic.LoadInt32(&r.spillPending) == 0 {
+		return
+	}
+
+	r.spillMutex.Lock()
+	defer r.spillMutex.Unlock()
+
+	// buffers handed over before spilling started are older
+	readyLen := len(r.fullBuffer)
+	for i := 0; i < readyLen; i++ {
+		buff := <-r.fullBuffer
+		r.writeBuffer(buff)
+		buff.recycle()
+	}
+
+	if _, err := r.spillFile.Seek(0, io.SeekStart); err != nil {
+		r.reportError(err)
+		return
+	}
+	// not taken from a pool, it's never recycled
+	var chunk poolBuffer
+	for {
+		chunk.Reset()
+		n, err := io.CopyN(&chunk, r.spillFile, spillChunkSize)
+		if n > 0 {
+			r.writeBuffer(&chunk)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			r.reportError(err)
+			return
+		}
+	}
+	if err := r.spillFile.Truncate(0); err != nil {
+		r.reportError(err)
+	}
+	if _, err := r.spillFile.Seek(0, io.SeekStart); err != nil {
+		r.reportError(err)
+	}
+	r.spilled = false
+	atomic.StoreInt32(&r.spillPending, 0)
+}
+
+// removeSpill removes the drained spill queue.
+func (r *RollingFile) removeSpill() {
+	r.spillMutex.Lock()
+	defer r.spillMutex.Unlock()
+
+	if r.spillFile == nil {
+		return
+	}
+	r.spillFile.Close()
+	os.Remove(r.spillFile.Name())
+	r.spillFile = nil
+}
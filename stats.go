@@ -0,0 +1,79 @@
+package logger
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// RollingStats : Counters of a RollingFile, exported to alert on silent log loss
+type RollingStats struct {
+	// BytesWritten is the number of bytes written to files.
+	BytesWritten int64
+	// BytesAccepted is the number of bytes of accepted Write calls.
+	BytesAccepted int64
+	// Writes is the number of accepted Write calls.
+	Writes int64
+	// FileWrites is the number of writes to files.
+	FileWrites int64
+	// Syncs is the number of fsyncs.
+	Syncs int64
+	// Dropped is the number of writes dropped because the buffer pool was exhausted.
+	Dropped int64
+	// Spilled is the number of bytes spilled to the on-disk overflow queue.
+	Spilled int64
+	// Rolls is the number of times the active file was rolled.
+	Rolls int64
+	// LastRoll is when the active file was last rolled.
+	LastRoll time.Time
+	// LastError is the last error writing, syncing or compressing files.
+	LastError error
+	// LastErrorTime is when LastError occurred.
+	LastErrorTime time.Time
+}
+
+// rollingCounters are the counters of RollingStats updated atomically by
+// writes, apart from the fields guarded by statsMutex.
+type rollingCounters struct {
+	BytesWritten  int64
+	BytesAccepted int64
+	Writes        int64
+	FileWrites    int64
+	Syncs         int64
+	Spilled       int64
+	Rolls         int64
+}
+
+// Stats : Snapshot of the counters of the file
+func (r *RollingFile) Stats() RollingStats {
+	stats := RollingStats{
+		BytesWritten:  atomic.LoadInt64(&r.counters.BytesWritten),
+		BytesAccepted: atomic.LoadInt64(&r.counters.BytesAccepted),
+		Writes:        atomic.LoadInt64(&r.counters.Writes),
+		FileWrites:    atomic.LoadInt64(&r.counters.FileWrites),
+		Syncs:         atomic.LoadInt64(&r.counters.Syncs),
+		Dropped:       atomic.LoadInt64(&r.dropped),
+		Spilled:       atomic.LoadInt64(&r.counters.Spilled),
+		Rolls:         atomic.LoadInt64(&r.counters.Rolls),
+	}
+
+	r.statsMutex.Lock()
+	stats.LastRoll = r.lastRoll
+	stats.LastError = r.lastError
+	stats.LastErrorTime = r.lastErrorTime
+	r.statsMutex.Unlock()
+	return stats
+}
+
+func (r *RollingFile) recordRoll() {
+	atomic.AddInt64(&r.counters.Rolls, 1)
+	r.statsMutex.Lock()
+	r.lastRoll = time.Now()
+	r.statsMutex.Unlock()
+}
+
+func (r *RollingFile) recordError(err error) {
+	r.statsMutex.Lock()
+	r.lastError = err
+	r.lastErrorTime = time.Now()
+	r.statsMutex.Unlock()
+}
@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Uploader stores rotated files off-host, such as in S3 or Aliyun OSS buckets.
+type Uploader interface {
+	Upload(ctx context.Context, key string, body io.Reader, size int64) error
+}
+
+// UploadFunc adapts a function to Uploader, e.g. wrapping the PutObject of
+// an SDK client.
+type UploadFunc func(ctx context.Context, key string, body io.Reader, size int64) error
+
+// Upload calls f.
+func (f UploadFunc) Upload(ctx context.Context, key string, body io.Reader, size int64) error {
+	return f(ctx, key, body, size)
+}
+
+// PresignedUploader uploads objects with PUT requests to presigned URLs,
+// which S3 and OSS both accept, without depending on their SDKs.
+type PresignedUploader struct {
+	// Presign returns the presigned PUT URL of key.
+	Presign func(key string) (string, error)
+	// Client sends requests, default is http.DefaultClient.
+	Client *http.Client
+}
+
+// Upload puts body to the presigned URL of key.
+func (u PresignedUploader) Upload(ctx context.Context, key string, body io.Reader, size int64) error {
+	url, err := u.Presign(key)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+
+	client := u.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("upload %s: unexpected status %s", key, resp.Status)
+	}
+	return nil
+}
+
+// UploadRotated returns a hook for WithOnRotated uploading rotated files with
+// u, the key of a file is prefix joined with its path relative to baseDir.
+// Local files are removed once uploaded if remove is true.
+func UploadRotated(u Uploader, baseDir, prefix string, remove bool) func(path string) error {
+	return func(file string) error {
+		rel, err := filepath.Rel(baseDir, file)
+		if err != nil {
+			return err
+		}
+		key := path.Join(prefix, filepath.ToSlash(rel))
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		if err := u.Upload(context.Background(), key, f, info.Size()); err != nil {
+			return err
+		}
+		if remove {
+			f.Close()
+			return os.Remove(file)
+		}
+		return nil
+	}
+}
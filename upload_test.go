@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadRotated(t *testing.T) {
+	var (
+		mutex   sync.Mutex
+		objects = make(map[string][]byte)
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := io.ReadAll(req.Body)
+		mutex.Lock()
+		objects[req.URL.Path] = b
+		mutex.Unlock()
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	r, err := NewRollingFile(filepath.Join(dir, "info"), HourlyRolling)
+	assert.NoError(t, err)
+	r.SetRollingFunc(func(name string, t time.Time) (string, string) {
+		return "", name
+	})
+	r.SetMaxSize(int64(len(msg)))
+	r.SetCompression(GzipCompression, 0)
+	r.SetOnRotated(UploadRotated(PresignedUploader{
+		Presign: func(key string) (string, error) { return srv.URL + "/" + key, nil },
+	}, dir, "logs", true))
+
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Sync())
+	r.Write([]byte(msg))
+	assert.NoError(t, r.Close())
+
+	assert.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		_, err := os.Stat(filepath.Join(dir, "info.log.gz"))
+		return len(objects) == 1 && os.IsNotExist(err)
+	}, time.Second, 10*time.Millisecond)
+
+	mutex.Lock()
+	gr, err := gzip.NewReader(bytes.NewReader(objects["/logs/info.log.gz"]))
+	mutex.Unlock()
+	assert.NoError(t, err)
+	b, _ := io.ReadAll(gr)
+	assert.Equal(t, msg, string(b))
+}
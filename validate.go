@@ -0,0 +1,178 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// OptionError is an error that indicates an invalid option or combination of
+// options, Option names the option to fix.
+type OptionError struct {
+	Option string
+	Reason string
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("invalid option %s: %s", e.Option, e.Reason)
+}
+
+func optionError(option, format string, args ...interface{}) error {
+	return &OptionError{Option: option, Reason: fmt.Sprintf(format, args...)}
+}
+
+// Validate reports invalid options and combinations of options, errors are
+// *OptionError combined by multierr.
+func (o Options) Validate() error {
+	var errs []error
+	if o.levelErr != nil {
+		errs = append(errs, optionError("WithLevelString", "%v", o.levelErr))
+	}
+	if !o.level.valid() {
+		errs = append(errs, optionError("WithLevel", "unknown level %d", o.level))
+	}
+	if o.stacktraceLevel != 0 && !o.stacktraceLevel.valid() {
+		errs = append(errs, optionError("WithStacktraceLevel", "unknown level %d", o.stacktraceLevel))
+	}
+	if o.encoder != JsonEncoder && o.encoder != ConsoleEncoder {
+		errs = append(errs, optionError("WithEncoder", "unknown encoder %q", o.encoder))
+	}
+	if o.consoleEncoder != "" && !o.consoleEncoder.IsJson() && !o.consoleEncoder.IsConsole() {
+		errs = append(errs, optionError("WithConsoleEncoder", "unknown encoder %q", o.consoleEncoder))
+	}
+	if o.fileEncoder != "" && !o.fileEncoder.IsJson() && !o.fileEncoder.IsConsole() {
+		errs = append(errs, optionError("WithFileEncoder", "unknown encoder %q", o.fileEncoder))
+	}
+	if o.disableDisk && o.filename != "" {
+		errs = append(errs, optionError("WithFilename", "file %q is never written since disk output is disabled", o.filename))
+	}
+	if o.disableDisk && !o.console && len(o.pipelines) == 0 && o.jsonCopy == nil {
+		errs = append(errs, optionError("WithConsole", "console and disk outputs are both disabled, entries are discarded"))
+	}
+	if !o.disableDisk && o.basePath == "" && o.rotator == nil {
+		errs = append(errs, optionError("WithBasePath", "base path is required to write files"))
+	}
+	if o.flushInterval <= 0 {
+		errs = append(errs, optionError("WithFlushInterval", "interval must be positive, got %s", o.flushInterval))
+	}
+	if o.flushThreshold <= 0 {
+		errs = append(errs, optionError("WithFlushThreshold", "threshold must be positive, got %d", o.flushThreshold))
+	}
+	if o.syncPolicy == SyncPeriodically && o.syncInterval <= 0 {
+		errs = append(errs, optionError("WithSyncPolicy", "SyncPeriodically requires a positive interval"))
+	}
+	if o.bufferPoolSize < 0 {
+		errs = append(errs, optionError("WithBufferPoolSize", "size must not be negative, got %d", o.bufferPoolSize))
+	}
+	if o.maxSize < 0 {
+		errs = append(errs, optionError("WithMaxSize", "size must not be negative, got %d", o.maxSize))
+	}
+	if o.watchInterval < 0 {
+		errs = append(errs, optionError("WithWatchInterval", "interval must not be negative, got %s", o.watchInterval))
+	}
+	switch o.compression {
+	case NoCompression, GzipCompression, ZstdCompression:
+	default:
+		errs = append(errs, optionError("WithCompression", "unknown compression %q", o.compression))
+	}
+	if o.callerSkip < callerSkipOffset {
+		errs = append(errs, optionError("WithCallerSkip", "skip must not be negative, got %d", o.callerSkip-callerSkipOffset))
+	}
+	switch o.callerMode {
+	case CallerShort, CallerOff, CallerFull, CallerFullWithFunction:
+	default:
+		errs = append(errs, optionError("WithCaller", "unknown caller mode %d", o.callerMode))
+	}
+	if o.filename != "" && o.singleFile != "" {
+		errs = append(errs, optionError("WithSingleFile", "file %q is never written since filename %q is set", o.singleFile, o.filename))
+	}
+	for lv, filename := range o.levelFilenames {
+		if !lv.valid() {
+			errs = append(errs, optionError("WithLevelFilenames", "unknown level %d", lv))
+		} else if filename == "" {
+			errs = append(errs, optionError("WithLevelFilenames", "empty file name of level %s", lv))
+		}
+	}
+	for _, lv := range o.levelFiles {
+		if !lv.valid() {
+			errs = append(errs, optionError("WithLevelFiles", "unknown level %d", lv))
+		}
+	}
+	for lv, format := range o.levelRollingFormats {
+		if !lv.valid() {
+			errs = append(errs, optionError("WithLevelRollingFormats", "unknown level %d", lv))
+		} else if format == "" {
+			errs = append(errs, optionError("WithLevelRollingFormats", "empty rolling format of level %s", lv))
+		}
+	}
+	if o.maxTotalSize < 0 {
+		errs = append(errs, optionError("WithMaxTotalSize", "size must not be negative, got %d", o.maxTotalSize))
+	}
+	if o.writeRateLimit < 0 {
+		errs = append(errs, optionError("WithWriteRateLimit", "limit must not be negative, got %d", o.writeRateLimit))
+	}
+	if o.sampling.tick < 0 || o.sampling.first < 0 || o.sampling.thereafter < 0 {
+		errs = append(errs, optionError("WithSampling", "tick, first and thereafter must not be negative"))
+	}
+	if o.bufferedOutput.size < 0 || o.bufferedOutput.flushInterval < 0 {
+		errs = append(errs, optionError("WithBufferedOutput", "size and flush interval must not be negative"))
+	}
+	if o.baggageSource == nil && len(o.baggageKeys) > 0 {
+		errs = append(errs, optionError("WithBaggageFields", "source must not be nil"))
+	}
+	if o.sampledTrace != nil && !o.sampledTraceLevel.valid() {
+		errs = append(errs, optionError("WithSampledTraceLevel", "unknown level %d", o.sampledTraceLevel))
+	}
+	if o.maxMessageSize < 0 {
+		errs = append(errs, optionError("WithMaxMessageSize", "size must not be negative, got %d", o.maxMessageSize))
+	}
+	names := make(map[string]bool, len(o.contextKeys))
+	for _, key := range o.contextKeys {
+		name, ok := contextKeyName(key)
+		if !ok {
+			errs = append(errs, optionError("WithContextKeys", "key of type %T must be a string or a fmt.Stringer", key))
+		} else if names[name] {
+			errs = append(errs, optionError("WithContextKeys", "keys named %q alike", name))
+		}
+		names[name] = true
+	}
+	if o.lazyFlush < 0 {
+		errs = append(errs, optionError("WithLazyFlush", "idle period must not be negative, got %s", o.lazyFlush))
+	}
+	return multierr.Combine(errs...)
+}
+
+// reportOptionError writes err to the error output of o, New goes on with
+// the options as they are.
+func (o Options) reportOptionError(err error) {
+	w := o.errorOutput
+	if w == nil {
+		w = zapcore.Lock(os.Stderr)
+	}
+	fmt.Fprintf(w, "%v logger: %v\n", time.Now().UTC(), err)
+	w.Sync()
+}
+
+// NewWithError is like New but returns invalid options and errors creating
+// outputs instead of panicking.
+func NewWithError(opts ...Option) (Logger, error) {
+	opt := newOptions(opts...)
+	if err := opt.Validate(); err != nil {
+		return nil, err
+	}
+
+	l := &logger{
+		opt:         opt,
+		atomicLevel: zap.NewAtomicLevelAt(opt.level.unmarshalZapLevel()),
+		bases:       new(atomic.Value),
+	}
+	if err := l.build(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
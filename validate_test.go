@@ -0,0 +1,73 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestNewWithError(t *testing.T) {
+	log, err := NewWithError(WithConsole(false), WithDisableDisk(true))
+	assert.Nil(t, log)
+	var optErr *OptionError
+	assert.True(t, errors.As(err, &optErr))
+	assert.Equal(t, "WithConsole", optErr.Option)
+
+	_, err = NewWithError(WithFilename("slow"), WithFlushInterval(0), WithLevel(Level(42)))
+	errs := multierr.Errors(err)
+	assert.Len(t, errs, 3)
+
+	log, err = NewWithError()
+	assert.NoError(t, err)
+	assert.NotNil(t, log)
+
+	var buf syncBuffer
+	log, err = NewWithError(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf))
+	assert.NoError(t, err)
+	log.Info(msg)
+	assert.Contains(t, buf.String(), msg)
+}
+
+func TestValidate(t *testing.T) {
+	opt := newOptions(
+		WithBasePath("logs"),
+		WithDisableDisk(false),
+		WithCallerSkip(-3),
+		WithFilename("app"),
+		WithSingleFile("all"),
+		WithLevelFilenames(map[Level]string{InfoLevel: ""}),
+		WithMaxMessageSize(-1),
+	)
+
+	var options []string
+	for _, err := range multierr.Errors(opt.Validate()) {
+		var optErr *OptionError
+		assert.True(t, errors.As(err, &optErr))
+		options = append(options, optErr.Option)
+	}
+	assert.Equal(t, []string{"WithCallerSkip", "WithSingleFile", "WithLevelFilenames", "WithMaxMessageSize"}, options)
+}
+
+func TestNewLevelString(t *testing.T) {
+	var errBuf syncBuffer
+	log := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(io.Discard),
+		WithErrorOutput(zapcore.AddSync(&errBuf)), WithLevelString("bogus"))
+	assert.Equal(t, Level(InfoLevel), log.Options().Level())
+	assert.Contains(t, errBuf.String(), "WithLevelString")
+
+	// every invalid option is reported
+	errBuf = syncBuffer{}
+	New(WithConsole(false), WithDisableDisk(true), WithDualFormat(io.Discard),
+		WithErrorOutput(zapcore.AddSync(&errBuf)), WithCallerSkip(-3), WithMaxMessageSize(-1))
+	assert.Contains(t, errBuf.String(), "WithCallerSkip")
+	assert.Contains(t, errBuf.String(), "WithMaxMessageSize")
+
+	// a later level replaces the unknown one
+	_, err := NewWithError(WithConsole(false), WithDisableDisk(true), WithDualFormat(io.Discard),
+		WithLevelString("bogus"), WithLevel(WarnLevel))
+	assert.NoError(t, err)
+}
@@ -5,7 +5,12 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 )
 
 var (
@@ -19,12 +24,44 @@ var (
 // Valuer is returns a log value.
 type Valuer func(ctx context.Context) interface{}
 
-// Value return the function value.
+// Binder resolves v at log time if it's a value of a type it knows, such as
+// a lazily evaluated context value, reporting whether it did.
+type Binder func(ctx context.Context, v interface{}) (interface{}, bool)
+
+var binders struct {
+	sync.Mutex
+	list atomic.Value // []Binder
+}
+
+// RegisterBinder adds b to the binders resolving values at log time, after
+// Valuer and the binders registered before. It's meant to be called during
+// initialization.
+func RegisterBinder(b Binder) {
+	binders.Lock()
+	defer binders.Unlock()
+	list, _ := binders.list.Load().([]Binder)
+	binders.list.Store(append(list[:len(list):len(list)], b))
+}
+
+// Value return the function value, or the value resolved by the first binder
+// knowing v.
 func Value(ctx context.Context, v interface{}) interface{} {
+	r, _ := bind(ctx, v)
+	return r
+}
+
+// bind returns the value of v like Value, reporting whether it was resolved.
+func bind(ctx context.Context, v interface{}) (interface{}, bool) {
 	if v, ok := v.(Valuer); ok {
-		return v(ctx)
+		return v(ctx), true
 	}
-	return v
+	list, _ := binders.list.Load().([]Binder)
+	for _, b := range list {
+		if r, ok := b(ctx, v); ok {
+			return r, true
+		}
+	}
+	return v, false
 }
 
 // Caller returns a Valuer that returns a pkg/file:line description of the caller.
@@ -47,19 +84,93 @@ func Timestamp(layout string) Valuer {
 	}
 }
 
-func bindValues(ctx context.Context, keyvals []interface{}) {
-	for i := 1; i < len(keyvals); i += 2 {
-		if v, ok := keyvals[i].(Valuer); ok {
-			keyvals[i] = v(ctx)
+// bindValues returns args with every value resolved by Value. args may
+// belong to the caller, it's copied rather than modified.
+func bindValues(ctx context.Context, args []interface{}) []interface{} {
+	copied := false
+	for i, v := range args {
+		r, ok := bind(ctx, v)
+		if !ok {
+			continue
+		}
+		if !copied {
+			args, copied = append([]interface{}{}, args...), true
+		}
+		args[i] = r
+	}
+	return args
+}
+
+// bindFields returns fields with every value resolved by Value, copied like
+// by bindValues.
+func bindFields(ctx context.Context, fields map[string]interface{}) map[string]interface{} {
+	var bound map[string]interface{}
+	for k, v := range fields {
+		r, ok := bind(ctx, v)
+		if !ok {
+			continue
+		}
+		if bound == nil {
+			bound = make(map[string]interface{}, len(fields))
+			for k, v := range fields {
+				bound[k] = v
+			}
 		}
+		bound[k] = r
 	}
+	if bound == nil {
+		return fields
+	}
+	return bound
+}
+
+// contextField carries the context of an entry to the cores, encoders ignore it.
+func contextField(ctx context.Context) zap.Field {
+	return zap.Field{Type: zapcore.SkipType, Interface: ctx}
+}
+
+// withContext appends the context field to the fields of an entry written
+// after the middlewares. Only pipelines use it, for the deadline of their
+// writes, so it's carried only to them and if ctx can be canceled.
+func (o *Options) withContext(ctx context.Context, fields []zap.Field) []zap.Field {
+	if len(o.pipelines) == 0 || ctx.Done() == nil {
+		return fields
+	}
+	return append(fields[:len(fields):len(fields)], contextField(ctx))
+}
+
+// noContextCore removes the context field before the outputs other than
+// pipelines see it.
+type noContextCore struct {
+	zapcore.Core
 }
 
-func containsValuer(keyvals []interface{}) bool {
-	for i := 1; i < len(keyvals); i += 2 {
-		if _, ok := keyvals[i].(Valuer); ok {
-			return true
+func (c *noContextCore) With(fields []zapcore.Field) zapcore.Core {
+	return &noContextCore{Core: c.Core.With(fields)}
+}
+
+func (c *noContextCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *noContextCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	_, fields = extractContext(fields)
+	return c.Core.Write(ent, fields)
+}
+
+// extractContext returns the context carried by fields and the fields without it.
+func extractContext(fields []zap.Field) (context.Context, []zap.Field) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Type != zapcore.SkipType {
+			continue
+		}
+		if ctx, ok := fields[i].Interface.(context.Context); ok {
+			rest := make([]zap.Field, 0, len(fields)-1)
+			return ctx, append(append(rest, fields[:i]...), fields[i+1:]...)
 		}
 	}
-	return false
+	return nil, fields
 }
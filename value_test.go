@@ -1,8 +1,12 @@
 package logger
 
 import (
+	"bytes"
 	"context"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestValue(t *testing.T) {
@@ -20,3 +24,47 @@ func TestValue(t *testing.T) {
 		t.Errorf("Value() = %v, want %v", res, 3)
 	}
 }
+
+type traceKey struct{}
+
+type lazyRequestID struct{}
+
+func TestRegisterBinder(t *testing.T) {
+	prev := binders.list.Load()
+	defer func() {
+		if prev != nil {
+			binders.list.Store(prev)
+		} else {
+			binders.list.Store([]Binder(nil))
+		}
+	}()
+
+	RegisterBinder(func(ctx context.Context, v interface{}) (interface{}, bool) {
+		if _, ok := v.(lazyRequestID); !ok {
+			return nil, false
+		}
+		return ctx.Value(traceKey{}), true
+	})
+
+	var buf bytes.Buffer
+	log := New(WithConsole(false), WithDisableDisk(true), WithDualFormat(&buf))
+	ctx := context.WithValue(context.Background(), traceKey{}, "r1")
+	clog := log.WithContext(ctx)
+	clog.Infow(msg, "k", lazyRequestID{})
+	log.(ContextLogger).InfoCtx(ctx, msg, "k", lazyRequestID{})
+	clog.WithFields(map[string]interface{}{"k": lazyRequestID{}}).Info(msg)
+	args := []interface{}{lazyRequestID{}}
+	clog.Info(args...)
+	clog.Infof("%v", lazyRequestID{})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 5)
+	for _, line := range lines[:3] {
+		assert.Contains(t, line, `"k":"r1"`)
+	}
+	for _, line := range lines[3:] {
+		assert.Contains(t, line, `"msg":"r1"`)
+	}
+	// the arguments of the caller are left as they are
+	assert.Equal(t, lazyRequestID{}, args[0])
+}
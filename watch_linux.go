@@ -0,0 +1,55 @@
+//go:build linux
+// +build linux
+
+package logger
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+)
+
+// watchFile notifies changes of the directory of path with inotify, files
+// replaced by a rename are notified as well, such as the config files saved
+// by editors or the ..data symlink swapped by Kubernetes ConfigMap volumes.
+// stop releases the watch.
+//
+// A single directory watch doesn't warrant a dependency on fsnotify: inotify
+// is used directly on Linux, where containers reload their configs, and the
+// other platforms rely on the polling of Watch.
+func watchFile(path string) (changes <-chan struct{}, stop func(), err error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, nil, err
+	}
+	const mask = syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_CREATE | syscall.IN_MOVED_TO
+	wd, err := syscall.InotifyAddWatch(fd, filepath.Dir(path), mask)
+	if err != nil {
+		syscall.Close(fd)
+		return nil, nil, err
+	}
+
+	ch := make(chan struct{}, 1)
+	var stopped int32
+	go func() {
+		defer syscall.Close(fd)
+		buf := make([]byte, 4096)
+		for {
+			// removing the watch queues an event, which ends the read
+			n, err := syscall.Read(fd, buf)
+			if atomic.LoadInt32(&stopped) == 1 || err != nil && err != syscall.EINTR {
+				return
+			}
+			if n > 0 {
+				select {
+				case ch <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return ch, func() {
+		atomic.StoreInt32(&stopped, 1)
+		syscall.InotifyRmWatch(fd, uint32(wd))
+	}, nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package logger
+
+import "errors"
+
+// watchFile isn't supported on this platform, config files are polled.
+func watchFile(path string) (changes <-chan struct{}, stop func(), err error) {
+	return nil, nil, errors.New("logger: file notifications not supported")
+}